@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,13 +18,11 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/config"
-	gitHttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/leraptor65/simple-data-flow/gitops"
 	"github.com/leraptor65/simple-data-flow/models"
 	"github.com/leraptor65/simple-data-flow/watcher"
@@ -34,6 +33,9 @@ const maxImageUploadSize = 10 << 20 // 10 MB
 
 var validGitHash = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
 
+// validGitRevision accepts a hash or HEAD, optionally with a parent suffix (HEAD~2, abc123^).
+var validGitRevision = regexp.MustCompile(`^(HEAD|[0-9a-f]{4,40})(~[0-9]{1,4}|\^)?$`)
+
 // safePath validates that a user-supplied relative path resolves within baseDir.
 // Returns the cleaned absolute path or an error if traversal is detected.
 func safePath(baseDir, userPath string) (string, error) {
@@ -74,20 +76,31 @@ func limitBody(r *http.Request, maxBytes int64) {
 type TreeItem struct {
 	Name         string      `json:"name"`
 	Path         string      `json:"path"`
-	Type         string      `json:"type"` // "file" or "folder"
+	Type         string      `json:"type"` // "file", "folder" or "smart_folder"
 	LastModified string      `json:"lastModified"`
+	Query        string      `json:"query,omitempty"` // saved search behind a smart_folder
 	Children     []*TreeItem `json:"children,omitempty"`
 }
 
 type API struct {
 	db      *sql.DB
 	dataDir string
+	git     *gitops.GitManager
+	ident   identityConfig
+	fuzzy   bool // pg_trgm is installed
 }
 
-func NewAPI(db *sql.DB, dataDir string) *API {
+func NewAPI(db *sql.DB, dataDir string, gitMgr *gitops.GitManager) *API {
+	var fuzzy bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&fuzzy); err != nil {
+		log.Printf("Checking for pg_trgm: %v", err)
+	}
 	return &API{
 		db:      db,
 		dataDir: dataDir,
+		git:     gitMgr,
+		ident:   loadIdentityConfig(),
+		fuzzy:   fuzzy,
 	}
 }
 
@@ -96,6 +109,16 @@ func (a *API) RegisterRoutes(r chi.Router) {
 	r.Get("/api/notes/*", a.HandleGetNote)
 	r.Post("/api/notes/*", a.HandleSaveNote)
 	r.Get("/api/search", a.HandleSearchNotes)
+	r.Get("/api/search/languages", a.HandleGetSearchLanguages)
+	r.Put("/api/search/languages", a.HandleSetFolderLanguage)
+	r.Delete("/api/search/languages", a.HandleDeleteFolderLanguage)
+	r.Get("/api/saved-searches", a.HandleListSavedSearches)
+	r.Post("/api/saved-searches", a.HandleCreateSavedSearch)
+	r.Delete("/api/saved-searches/{id}", a.HandleDeleteSavedSearch)
+	r.Get("/api/saved-searches/{id}/results", a.HandleSavedSearchResults)
+	r.Get("/api/query", a.HandleQueryNotes)
+	r.Get("/api/tags", a.HandleListTags)
+	r.Get("/api/tags/{tag}/notes", a.HandleGetTagNotes)
 	r.Post("/api/upload", a.HandleUploadImage)
 	r.Post("/api/folders", a.HandleCreateFolder)
 	r.Put("/api/move", a.HandleMoveItem)
@@ -104,8 +127,25 @@ func (a *API) RegisterRoutes(r chi.Router) {
 	r.Post("/api/sync", a.HandleSyncDatabase)
 	r.Get("/api/history", a.HandleGetHistory)
 	r.Get("/api/history/content", a.HandleGetHistoryContent)
+	r.Get("/api/history/diff", a.HandleGetHistoryDiff)
+	r.Get("/api/history/blame", a.HandleGetBlame)
+	r.Get("/api/history/deleted", a.HandleGetDeletedFiles)
+	r.Post("/api/history/restore", a.HandleRestoreDeletedFile)
+	r.Get("/api/activity", a.HandleGetActivity)
 	r.Post("/api/revert", a.HandleRevertFile)
 
+	r.Post("/api/snapshots", a.HandleCreateSnapshot)
+	r.Get("/api/snapshots", a.HandleListSnapshots)
+	r.Get("/api/snapshots/{id}/tree", a.HandleGetSnapshotTree)
+	r.Get("/api/snapshots/{id}/notes/*", a.HandleGetSnapshotNote)
+	r.Post("/api/snapshots/{id}/restore", a.HandleRestoreSnapshot)
+
+	r.Post("/api/drafts", a.HandleCreateDraft)
+	r.Get("/api/drafts", a.HandleListDrafts)
+	r.Get("/api/drafts/{id}/diff", a.HandleGetDraftDiff)
+	r.Post("/api/drafts/{id}/merge", a.HandleMergeDraft)
+	r.Delete("/api/drafts/{id}", a.HandleDiscardDraft)
+
 	r.Get("/api/recycle-bin", a.HandleGetRecycleBin)
 	r.Post("/api/recycle-bin/restore", a.HandleRestoreRecycledItem)
 	r.Delete("/api/recycle-bin/permanent", a.HandleDeleteRecycledItemPermanent)
@@ -136,9 +176,12 @@ func (a *API) RegisterRoutes(r chi.Router) {
 
 	// Git status info for Settings
 	r.Get("/api/git/status", a.HandleGetGitStatus)
+	r.Get("/api/git/logs", a.HandleGetSyncLogs)
 	r.Post("/api/git/toggle", a.HandleToggleGitSync)
 	r.Post("/api/git/check", a.HandleCheckGitConnection)
 	r.Post("/api/git/push", a.HandleGitPushAll)
+	r.Get("/api/git/conflicts", a.HandleGetGitConflicts)
+	r.Post("/api/git/conflicts/resolve", a.HandleResolveGitConflict)
 	r.Get("/api/version", a.HandleGetVersion)
 }
 
@@ -171,6 +214,11 @@ func (a *API) HandleGetNote(w http.ResponseWriter, r *http.Request) {
 	}
 	filename, _ = url.PathUnescape(filename)
 
+	if branch := r.URL.Query().Get("branch"); branch != "" {
+		a.getDraftNote(w, branch, filename)
+		return
+	}
+
 	var n models.Note
 	err := a.db.QueryRow("SELECT id, filename, title, COALESCE(frontmatter, '{}'), content, last_modified FROM notes WHERE filename = $1", filename).
 		Scan(&n.ID, &n.Filename, &n.Title, &n.Frontmatter, &n.Content, &n.LastModified)
@@ -222,6 +270,12 @@ func (a *API) HandleSaveNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Saves to a draft branch are committed there and never touch the vault on disk
+	if branch := r.URL.Query().Get("branch"); branch != "" {
+		a.saveDraftNote(w, r, branch, filename, req.Content)
+		return
+	}
+
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		log.Printf("HandleSaveNote mkdir: %v", err)
@@ -235,72 +289,19 @@ func (a *API) HandleSaveNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitAll("Update "+filename)
-	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
-
-	w.WriteHeader(http.StatusOK)
-}
-
-func buildFuzzyPattern(query string) string {
-	var sb strings.Builder
-	sb.WriteRune('%')
-	for _, r := range query {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			sb.WriteRune(r)
-			sb.WriteRune('%')
-		}
-	}
-	return sb.String()
-}
-
-func (a *API) HandleSearchNotes(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
-		return
-	}
-
-	fuzzyPattern := buildFuzzyPattern(query)
-
-	rows, err := a.db.Query(`
-		SELECT id, filename, title, frontmatter, last_modified
-		FROM (
-			SELECT DISTINCT ON (filename) id, filename, title, COALESCE(frontmatter, '{}') as frontmatter, last_modified,
-				(CASE 
-					WHEN filename ILIKE '%' || $1 || '%' THEN 10
-					WHEN filename ILIKE $2 THEN 5
-					WHEN content_vector @@ plainto_tsquery('english', $1) THEN 3
-					WHEN content ILIKE '%' || $1 || '%' THEN 1
-					ELSE 0
-				END) as rank
-			FROM notes 
-			WHERE filename ILIKE '%' || $1 || '%' 
-			   OR filename ILIKE $2 
-			   OR content_vector @@ plainto_tsquery('english', $1) 
-			   OR content ILIKE '%' || $1 || '%'
-		) sub
-		ORDER BY rank DESC, filename ASC
-	`, query, fuzzyPattern)
-
+	hash, err := a.git.CommitFile(r.Context(), filename, "Update "+filename)
+	// The note is on disk either way, so the index follows it
+	watcher.SyncPath(a.db, a.dataDir, filename)
 	if err != nil {
-		log.Printf("HandleSearchNotes: %v", err)
-		http.Error(w, "Search failed", http.StatusInternalServerError)
+		log.Printf("HandleSaveNote commit: %v", err)
+		http.Error(w, "Note was written but could not be committed to history", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var notes []models.Note
-	for rows.Next() {
-		var n models.Note
-		err := rows.Scan(&n.ID, &n.Filename, &n.Title, &n.Frontmatter, &n.LastModified)
-		if err == nil {
-			notes = append(notes, n)
-		}
-	}
 
 	setJSON(w)
-	json.NewEncoder(w).Encode(notes)
+	json.NewEncoder(w).Encode(map[string]string{
+		"commit": hash,
+	})
 }
 
 func (a *API) HandleUploadImage(w http.ResponseWriter, r *http.Request) {
@@ -327,7 +328,7 @@ func (a *API) HandleUploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	imagesDir := filepath.Join(a.dataDir, ".images")
+	imagesDir := a.git.ImagesDir()
 	os.MkdirAll(imagesDir, 0755)
 
 	dstPath := filepath.Join(imagesDir, safeFilename)
@@ -345,9 +346,6 @@ func (a *API) HandleUploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitFile(filepath.Join(".images", safeFilename), "Upload image "+safeFilename)
-
 	setJSON(w)
 	json.NewEncoder(w).Encode(map[string]string{
 		"url": "/images/" + safeFilename,
@@ -380,8 +378,7 @@ func (a *API) HandleCreateFolder(w http.ResponseWriter, r *http.Request) {
 	gitkeepPath := filepath.Join(fullPath, ".gitkeep")
 	os.WriteFile(gitkeepPath, []byte(""), 0644)
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitFile(filepath.Join(req.Path, ".gitkeep"), "Create folder "+req.Path)
+	a.git.CommitFile(r.Context(), filepath.Join(req.Path, ".gitkeep"), "Create folder "+req.Path)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -414,9 +411,16 @@ func (a *API) HandleMoveItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitAll("Move " + req.Source + " to " + req.Destination)
-	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	// Move the indexed rows along with the files so note IDs, links and tags survive
+	idx := watcher.NewWatcher(a.db, a.dataDir)
+	if info, statErr := os.Stat(destPath); statErr == nil && info.IsDir() {
+		idx.RenameFolder(srcPath, destPath)
+	} else {
+		idx.RenameFile(srcPath, destPath)
+	}
+
+	a.git.CommitPaths(r.Context(), []string{req.Source, req.Destination}, "Move "+req.Source+" to "+req.Destination)
+	watcher.SyncPath(a.db, a.dataDir, req.Destination)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -437,7 +441,7 @@ func (a *API) HandleDeleteItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recyclePath := filepath.Join(a.dataDir, ".recycle_bin")
+	recyclePath := a.git.RecycleBinDir()
 	if err := os.MkdirAll(recyclePath, 0755); err != nil {
 		log.Printf("HandleDeleteItem: %v", err)
 		http.Error(w, "Failed to delete item", http.StatusInternalServerError)
@@ -447,15 +451,14 @@ func (a *API) HandleDeleteItem(w http.ResponseWriter, r *http.Request) {
 	destPath := filepath.Join(recyclePath, filepath.Base(req.Path))
 
 	// Move file/folder to recycle bin
-	if err := os.Rename(sourcePath, destPath); err != nil {
+	if err := gitops.MovePath(sourcePath, destPath); err != nil {
 		log.Printf("HandleDeleteItem move: %v", err)
 		http.Error(w, "Failed to delete item", http.StatusInternalServerError)
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitAll("Moved " + req.Path + " to .recycle_bin")
-	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	a.git.CommitFile(r.Context(), req.Path, "Moved "+req.Path+" to the recycle bin")
+	watcher.SyncPath(a.db, a.dataDir, req.Path)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -546,6 +549,9 @@ func (a *API) HandleGetTree(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Saved searches follow the vault as virtual folders, filled in when opened
+	root.Children = append(root.Children, a.smartFolders()...)
+
 	setJSON(w)
 	json.NewEncoder(w).Encode(root.Children)
 }
@@ -557,8 +563,7 @@ func (a *API) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	commits, err := git.GetFileHistory(filename)
+	commits, err := a.git.GetFileHistory(filename)
 	if err != nil {
 		log.Printf("HandleGetHistory: %v", err)
 		http.Error(w, "Failed to get history", http.StatusInternalServerError)
@@ -585,14 +590,13 @@ func (a *API) HandleRevertFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	err := git.CheckoutFile(req.Hash, req.Filename)
+	err := a.git.CheckoutFile(r.Context(), req.Hash, req.Filename)
 	if err != nil {
 		log.Printf("HandleRevertFile: %v", err)
 		http.Error(w, "Failed to revert file", http.StatusInternalServerError)
 		return
 	}
-	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	watcher.SyncPath(a.db, a.dataDir, req.Filename)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -611,8 +615,7 @@ func (a *API) HandleGetHistoryContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	content, err := git.GetFileContentAtHash(hash, filename)
+	content, err := a.git.GetFileContentAtHash(hash, filename)
 	if err != nil {
 		log.Printf("HandleGetHistoryContent: %v", err)
 		http.Error(w, "Failed to get content", http.StatusInternalServerError)
@@ -622,8 +625,59 @@ func (a *API) HandleGetHistoryContent(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(content))
 }
 
+// HandleGetHistoryDiff returns a structured line/word diff of a note between two
+// revisions. "from" defaults to HEAD and "to" to the working copy; either may be
+// "working" to mean the file on disk.
+func (a *API) HandleGetHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filename := q.Get("file")
+	if filename == "" {
+		http.Error(w, "file parameter is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := safePath(a.dataDir, filename); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	from := q.Get("from")
+	if from == "" {
+		from = "HEAD"
+	}
+	to := q.Get("to")
+	if to == "" {
+		to = gitops.WorkingCopy
+	}
+	for _, rev := range []string{from, to} {
+		if rev != gitops.WorkingCopy && !validGitRevision.MatchString(rev) {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+	}
+
+	context := -1
+	if c := q.Get("context"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid context", http.StatusBadRequest)
+			return
+		}
+		context = n
+	}
+
+	diff, err := a.git.DiffFile(filename, from, to, context)
+	if err != nil {
+		log.Printf("HandleGetHistoryDiff: %v", err)
+		http.Error(w, "Failed to compute diff", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(diff)
+}
+
 func (a *API) HandleGetRecycleBin(w http.ResponseWriter, r *http.Request) {
-	recyclePath := filepath.Join(a.dataDir, ".recycle_bin")
+	recyclePath := a.git.RecycleBinDir()
 	if _, err := os.Stat(recyclePath); os.IsNotExist(err) {
 		setJSON(w)
 		json.NewEncoder(w).Encode([]TreeItem{})
@@ -671,18 +725,17 @@ func (a *API) HandleRestoreRecycledItem(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	sourcePath := filepath.Join(a.dataDir, ".recycle_bin", safeName)
+	sourcePath := filepath.Join(a.git.RecycleBinDir(), safeName)
 	destPath := filepath.Join(a.dataDir, safeName)
 
-	if err := os.Rename(sourcePath, destPath); err != nil {
+	if err := gitops.MovePath(sourcePath, destPath); err != nil {
 		log.Printf("HandleRestoreRecycledItem: %v", err)
 		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitAll("Restored " + safeName + " from .recycle_bin")
-	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	a.git.CommitFile(r.Context(), safeName, "Restored "+safeName+" from the recycle bin")
+	watcher.SyncPath(a.db, a.dataDir, safeName)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -704,7 +757,7 @@ func (a *API) HandleDeleteRecycledItemPermanent(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	targetPath := filepath.Join(a.dataDir, ".recycle_bin", safeName)
+	targetPath := filepath.Join(a.git.RecycleBinDir(), safeName)
 	if err := os.RemoveAll(targetPath); err != nil {
 		log.Printf("HandleDeleteRecycledItemPermanent: %v", err)
 		http.Error(w, "Failed to delete item", http.StatusInternalServerError)
@@ -735,24 +788,54 @@ func (a *API) HandleExportVault(w http.ResponseWriter, r *http.Request) {
 			return nil
 		}
 
-		if info.IsDir() {
+		// App state is exported separately below, if at all
+		if a.git.IsStatePath(relPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		file, err := os.Open(path)
-		if err != nil {
-			return err
+		if info.IsDir() {
+			return nil
 		}
-		defer file.Close()
 
-		f, err := zw.Create(relPath)
-		if err != nil {
-			return err
+		return addFileToZip(zw, path, relPath)
+	})
+
+	// Images live in the state directory but travel with the vault under .images/
+	imagesDir := a.git.ImagesDir()
+	entries, err := os.ReadDir(imagesDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
 		}
+		if err := addFileToZip(zw, filepath.Join(imagesDir, entry.Name()), path.Join(exportImagesDir, entry.Name())); err != nil {
+			log.Printf("HandleExportVault: %v", err)
+		}
+	}
+}
 
-		_, err = io.Copy(f, file)
+// exportImagesDir is the folder vault exports keep uploaded images in.
+const exportImagesDir = ".images"
+
+func addFileToZip(zw *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
 		return err
-	})
+	}
+	defer file.Close()
+
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, file)
+	return err
 }
 
 func (a *API) HandleImportVault(w http.ResponseWriter, r *http.Request) {
@@ -798,9 +881,21 @@ func (a *API) HandleImportVault(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		targetPath, err := safePath(a.dataDir, relPath)
-		if err != nil {
-			continue
+		var targetPath string
+		if image, ok := strings.CutPrefix(filepath.ToSlash(relPath), exportImagesDir+"/"); ok {
+			safeImage := sanitizeFilename(image)
+			if safeImage == "" {
+				continue
+			}
+			targetPath = filepath.Join(a.git.ImagesDir(), safeImage)
+		} else {
+			if a.git.IsStatePath(relPath) {
+				continue
+			}
+			targetPath, err = safePath(a.dataDir, relPath)
+			if err != nil {
+				continue
+			}
 		}
 
 		if zf.FileInfo().IsDir() {
@@ -826,8 +921,7 @@ func (a *API) HandleImportVault(w http.ResponseWriter, r *http.Request) {
 		f.Close()
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitAll("Vault imported from ZIP")
+	a.git.CommitAll(r.Context(), "Vault imported from ZIP")
 	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
 
 	w.WriteHeader(http.StatusOK)
@@ -1161,11 +1255,11 @@ func (a *API) HandleServeImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullPath := filepath.Join(a.dataDir, ".images", imagePath)
+	fullPath := filepath.Join(a.git.ImagesDir(), imagePath)
 
 	// Security check: prevent path traversal
 	cleanPath := filepath.Clean(fullPath)
-	if !strings.HasPrefix(cleanPath, filepath.Join(a.dataDir, ".images")) {
+	if !strings.HasPrefix(cleanPath, a.git.ImagesDir()) {
 		http.Error(w, "Invalid path", http.StatusForbidden)
 		return
 	}
@@ -1236,9 +1330,9 @@ func (a *API) HandleServeSharedImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 5. Serve the image
-	fullPath := filepath.Join(a.dataDir, ".images", safeImage)
+	fullPath := filepath.Join(a.git.ImagesDir(), safeImage)
 	cleanPath := filepath.Clean(fullPath)
-	if !strings.HasPrefix(cleanPath, filepath.Join(a.dataDir, ".images")) {
+	if !strings.HasPrefix(cleanPath, a.git.ImagesDir()) {
 		http.Error(w, "Invalid path", http.StatusForbidden)
 		return
 	}
@@ -1292,12 +1386,19 @@ func (a *API) HandleGetBacklinks(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *API) HandleGetGitStatus(w http.ResponseWriter, r *http.Request) {
-	repo := os.Getenv("GITHUB_REPO")
+	remotes, remotesErr := gitops.DescribeRemotes()
+	repo := ""
+	if len(remotes) > 0 {
+		repo = remotes[0].URL
+	}
+	remotesError := ""
+	if remotesErr != nil {
+		remotesError = remotesErr.Error()
+	}
 
 	// Read local config file if exists
 	disabled := false
-	configPath := filepath.Join(a.dataDir, ".git_config.json")
-	if data, err := os.ReadFile(configPath); err == nil {
+	if data, err := os.ReadFile(a.git.SyncConfigPath()); err == nil {
 		var cfg struct {
 			Disabled bool `json:"disabled"`
 		}
@@ -1348,13 +1449,18 @@ func (a *API) HandleGetGitStatus(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	gitMgr := gitops.NewGitManager(a.dataDir)
-	logs := gitMgr.GetSyncLogs()
+	logs, err := a.git.GetSyncLogs(gitops.SyncLogFilter{Limit: recentSyncLogs})
+	if err != nil {
+		log.Printf("HandleGetGitStatus: reading sync logs: %v", err)
+		logs = []gitops.SyncLogEntry{}
+	}
 
 	status := map[string]interface{}{
 		"enabled":       repo != "",
 		"sync_disabled": disabled,
 		"repo":          repo,
+		"remotes":       remotes,
+		"remotes_error": remotesError,
 		"gh_installed":  ghInstalled,
 		"gh_logged_in":  ghLoggedIn,
 		"gh_status":     ghStatus,
@@ -1362,6 +1468,8 @@ func (a *API) HandleGetGitStatus(w http.ResponseWriter, r *http.Request) {
 		"author_name":   userName,
 		"author_email":  userEmail,
 		"sync_logs":     logs,
+		"sync":          a.git.GetSyncStatus(),
+		"signing":       a.git.SigningStatus(),
 	}
 
 	setJSON(w)
@@ -1378,7 +1486,7 @@ func (a *API) HandleToggleGitSync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	configPath := filepath.Join(a.dataDir, ".git_config.json")
+	configPath := a.git.SyncConfigPath()
 	configData, err := json.MarshalIndent(req, "", "  ")
 	if err != nil {
 		http.Error(w, "Failed to serialize config", http.StatusInternalServerError)
@@ -1394,43 +1502,25 @@ func (a *API) HandleToggleGitSync(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *API) HandleCheckGitConnection(w http.ResponseWriter, r *http.Request) {
-	repoURL := os.Getenv("GITHUB_REPO")
-	if repoURL == "" {
+	remotes, err := gitops.LoadRemotes()
+	if err != nil {
 		setJSON(w)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": "GITHUB_REPO environment variable is not configured.",
+			"message": fmt.Sprintf("Invalid remote configuration: %v", err),
 		})
 		return
 	}
-
-	// Get token from gh auth
-	var token string
-	cmd := exec.Command("gh", "auth", "token")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = nil
-	if err := cmd.Run(); err == nil {
-		token = strings.TrimSpace(out.String())
-	}
-
-	if token == "" {
-		// Also try to get gh auth status for diagnostics
-		ghStatus, _ := gitops.GetGHAuthStatus()
-		msg := "No GitHub token available. Run 'gh auth login' on the host machine."
-		if ghStatus != "" {
-			msg += "\n\ngh auth status output:\n" + ghStatus
-		}
+	if len(remotes) == 0 {
 		setJSON(w)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": msg,
+			"message": "No git remote is configured. Set GIT_REMOTE_URL (or GITHUB_REPO) or GIT_REMOTES_FILE.",
 		})
 		return
 	}
 
-	gitMgr := gitops.NewGitManager(a.dataDir)
-	repo := gitMgr.InitRepo()
+	repo := a.git.InitRepo()
 	if repo == nil {
 		setJSON(w)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1440,54 +1530,34 @@ func (a *API) HandleCheckGitConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	remoteName := "origin"
-	remotes, err := repo.Remotes()
+	// Try listing remote references to test connection and auth for every remote
+	checks, err := a.git.CheckRemotes()
 	if err != nil {
 		setJSON(w)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": fmt.Sprintf("Failed to list remotes: %v", err),
+			"message": fmt.Sprintf("Failed to check remotes: %v", err),
 		})
 		return
 	}
 
-	var originRemote *git.Remote
-	for _, r := range remotes {
-		if r.Config().Name == remoteName {
-			originRemote = r
-			break
+	var failures []string
+	for _, c := range checks {
+		if !c.Success {
+			failures = append(failures, fmt.Sprintf("%s: %s", c.Name, c.Error))
 		}
 	}
-
-	if originRemote == nil {
-		originRemote, err = repo.CreateRemote(&config.RemoteConfig{
-			Name: remoteName,
-			URLs: []string{repoURL},
-		})
-		if err != nil {
-			setJSON(w)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"success": false,
-				"message": fmt.Sprintf("Failed to create remote: %v", err),
-			})
-			return
+	if len(failures) > 0 {
+		msg := "Authentication/Connection failed: " + strings.Join(failures, "; ")
+		// gh diagnostics only help when a GitHub remote relies on gh for its token
+		if ghStatus, _ := gitops.GetGHAuthStatus(); ghStatus != "" && strings.Contains(msg, "github.com") {
+			msg += "\n\ngh auth status output:\n" + ghStatus
 		}
-	}
-
-	auth := &gitHttp.BasicAuth{
-		Username: "git",
-		Password: token,
-	}
-
-	// Try listing remote references to test connection and auth
-	_, err = originRemote.List(&git.ListOptions{
-		Auth: auth,
-	})
-	if err != nil {
 		setJSON(w)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": fmt.Sprintf("Authentication/Connection failed: %v", err),
+			"message": msg,
+			"remotes": checks,
 		})
 		return
 	}
@@ -1497,13 +1567,13 @@ func (a *API) HandleCheckGitConnection(w http.ResponseWriter, r *http.Request) {
 	setJSON(w)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Successfully authenticated and connected to GitHub repository!",
+		"message": fmt.Sprintf("Successfully authenticated and connected to %d remote(s)!", len(checks)),
+		"remotes": checks,
 	})
 }
 
 func (a *API) HandleGitPushAll(w http.ResponseWriter, r *http.Request) {
-	gitMgr := gitops.NewGitManager(a.dataDir)
-	repo := gitMgr.InitRepo()
+	repo := a.git.InitRepo()
 	if repo == nil {
 		setJSON(w)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1514,31 +1584,76 @@ func (a *API) HandleGitPushAll(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Println("GitHub Sync: manual push-all triggered from settings")
-	// CommitAll commits untracked and modified changes, pulling and pushing remote under the hood
-	gitMgr.CommitAll("Sync all notes to GitHub")
+	// Commit any outstanding changes, then pull and push right away instead of waiting for the scheduler
+	a.git.CommitAll(r.Context(), "Sync all notes")
+	syncErr := a.git.SyncNow()
 	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
 
-	// Get latest logs to check for success/errors
-	logs := gitMgr.GetSyncLogs()
-	var lastErr string
-	if len(logs) > 0 && !logs[0].Success {
-		lastErr = logs[0].Error
-	}
-
 	setJSON(w)
-	if lastErr != "" {
+	if syncErr != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"message": "Push completed with warnings: " + lastErr,
+			"message": "Push completed with warnings: " + syncErr.Error(),
 		})
 	} else {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
-			"message": "Successfully committed and pushed all notes to GitHub!",
+			"message": "Successfully committed and pushed all notes!",
 		})
 	}
 }
 
+func (a *API) HandleGetGitConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := a.git.GetConflicts()
+	if err != nil {
+		log.Printf("HandleGetGitConflicts: %v", err)
+		http.Error(w, "Failed to get conflicts", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(conflicts)
+}
+
+func (a *API) HandleResolveGitConflict(w http.ResponseWriter, r *http.Request) {
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Path       string `json:"path"`
+		Resolution string `json:"resolution"` // "ours", "theirs" or "manual"
+		Content    string `json:"content"`    // required for "manual"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := safePath(a.dataDir, req.Path); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	result, err := a.git.ResolveConflict(r.Context(), req.Path, req.Resolution, req.Content)
+	switch {
+	case errors.Is(err, gitops.ErrNoMergeInProgress), errors.Is(err, gitops.ErrNotInConflict), errors.Is(err, gitops.ErrUnknownResolution):
+		http.Error(w, "Failed to resolve conflict: "+err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, gitops.ErrMergeDeferred):
+		// The resolution is kept; the merge completes on a later sync
+		http.Error(w, "Local edits are still being committed, try again shortly", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("HandleResolveGitConflict: %v", err)
+		http.Error(w, "Failed to resolve conflict", http.StatusInternalServerError)
+		return
+	}
+	if result.Commit != "" {
+		// The merge rewrote files from the remote side; bring the index up to date
+		watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(result)
+}
+
 func getVersion() string {
 	paths := []string{"VERSION", "../VERSION", "backend/VERSION"}
 	for _, p := range paths {
@@ -1557,7 +1672,7 @@ func (a *API) HandleGetVersion(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *API) HandleListImages(w http.ResponseWriter, r *http.Request) {
-	imagesDir := filepath.Join(a.dataDir, ".images")
+	imagesDir := a.git.ImagesDir()
 	var images []string
 	entries, err := os.ReadDir(imagesDir)
 	if err != nil {
@@ -1594,21 +1709,23 @@ func (a *API) HandleDeleteImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullPath := filepath.Join(a.dataDir, ".images", safe)
+	fullPath := filepath.Join(a.git.ImagesDir(), safe)
 	if err := os.Remove(fullPath); err != nil {
 		log.Printf("HandleDeleteImage: %v", err)
 		http.Error(w, "Failed to delete image", http.StatusInternalServerError)
 		return
 	}
 
-	git := gitops.NewGitManager(a.dataDir)
-	git.CommitAll("Delete image " + safe)
-
 	w.WriteHeader(http.StatusOK)
 }
 
 func (a *API) HandleSyncDatabase(w http.ResponseWriter, r *http.Request) {
-	watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	// ?full=true ignores stored fingerprints and re-indexes every note
+	if r.URL.Query().Get("full") == "true" {
+		watcher.ResyncAll(a.db, a.dataDir)
+	} else {
+		watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	}
 	setJSON(w)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leraptor65/simple-data-flow/gitops"
+	"github.com/leraptor65/simple-data-flow/models"
+	"github.com/leraptor65/simple-data-flow/watcher"
+)
+
+// writeDraftError maps gitops draft errors to HTTP responses.
+func writeDraftError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, gitops.ErrInvalidDraftID):
+		http.Error(w, "Invalid draft id", http.StatusBadRequest)
+	case errors.Is(err, gitops.ErrDraftNotFound):
+		http.Error(w, "Draft not found", http.StatusNotFound)
+	case errors.Is(err, gitops.ErrOutsideScope):
+		http.Error(w, "File is outside the draft's note or folder", http.StatusBadRequest)
+	case errors.Is(err, gitops.ErrFileNotFound):
+		http.Error(w, "Note not found in draft", http.StatusNotFound)
+	case errors.Is(err, gitops.ErrNothingToMerge):
+		http.Error(w, "Draft has no changes to merge", http.StatusBadRequest)
+	default:
+		log.Printf("%s: %v", handler, err)
+		http.Error(w, "Draft operation failed", http.StatusInternalServerError)
+	}
+}
+
+// HandleCreateDraft starts a draft branch for a note or folder.
+func (a *API) HandleCreateDraft(w http.ResponseWriter, r *http.Request) {
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := safePath(a.dataDir, req.Path); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	draft, err := a.git.CreateDraft(req.Path)
+	if err != nil {
+		writeDraftError(w, "HandleCreateDraft", err)
+		return
+	}
+
+	setJSON(w)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+func (a *API) HandleListDrafts(w http.ResponseWriter, r *http.Request) {
+	drafts, err := a.git.ListDrafts()
+	if err != nil {
+		writeDraftError(w, "HandleListDrafts", err)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(drafts)
+}
+
+// HandleGetDraftDiff diffs each file the draft changed against main.
+func (a *API) HandleGetDraftDiff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	context := -1
+	if c := r.URL.Query().Get("context"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid context", http.StatusBadRequest)
+			return
+		}
+		context = n
+	}
+
+	diffs, err := a.git.DiffDraft(id, context)
+	if err != nil {
+		writeDraftError(w, "HandleGetDraftDiff", err)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(diffs)
+}
+
+// HandleMergeDraft lands a draft on main as one commit. Overlapping edits on
+// main return 409 with the conflicting files unless "prefer" is "draft".
+func (a *API) HandleMergeDraft(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Prefer string `json:"prefer"` // "" or "draft"
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Prefer != "" && req.Prefer != "draft" {
+		http.Error(w, "prefer must be \"draft\" when set", http.StatusBadRequest)
+		return
+	}
+
+	draft, err := a.git.GetDraft(id)
+	if err != nil {
+		writeDraftError(w, "HandleMergeDraft", err)
+		return
+	}
+	hash, conflicts, err := a.git.MergeDraft(r.Context(), id, req.Prefer == "draft")
+	if errors.Is(err, gitops.ErrDraftConflicts) {
+		setJSON(w)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":     "Draft conflicts with changes on main",
+			"conflicts": conflicts,
+		})
+		return
+	}
+	if err != nil {
+		writeDraftError(w, "HandleMergeDraft", err)
+		return
+	}
+	watcher.SyncPath(a.db, a.dataDir, draft.Scope)
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]string{"commit": hash})
+}
+
+func (a *API) HandleDiscardDraft(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := a.git.DiscardDraft(id); err != nil {
+		writeDraftError(w, "HandleDiscardDraft", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// getDraftNote serves GET /api/notes/{path}?branch=<draft id>.
+func (a *API) getDraftNote(w http.ResponseWriter, branch, filename string) {
+	if _, err := safePath(a.dataDir, filename); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	content, err := a.git.DraftFileContent(branch, filename)
+	if err != nil {
+		writeDraftError(w, "HandleGetNote", err)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(models.Note{
+		Filename: filename,
+		Title:    filename,
+		Content:  content,
+	})
+}
+
+// saveDraftNote serves POST /api/notes/{path}?branch=<draft id>.
+func (a *API) saveDraftNote(w http.ResponseWriter, r *http.Request, branch, filename, content string) {
+	hash, err := a.git.SaveDraftFile(r.Context(), branch, filename, content, "Update "+filename)
+	if err != nil {
+		writeDraftError(w, "HandleSaveNote", err)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]string{
+		"commit": hash,
+		"branch": branch,
+	})
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/leraptor65/simple-data-flow/gitops"
+)
+
+// recentSyncLogs is how many sync events GET /api/git/status includes.
+const recentSyncLogs = 20
+
+// HandleGetSyncLogs returns recorded pull, merge and push attempts, newest
+// first. Supports operation, remote, error_class, status (success|failure),
+// since and until filters with limit/offset pagination.
+func (a *API) HandleGetSyncLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := gitops.SyncLogFilter{
+		Operation:  q.Get("operation"),
+		Remote:     q.Get("remote"),
+		ErrorClass: q.Get("error_class"),
+		Limit:      defaultQueryLimit,
+	}
+	switch q.Get("status") {
+	case "":
+	case "success":
+		ok := true
+		filter.Success = &ok
+	case "failure":
+		ok := false
+		filter.Success = &ok
+	default:
+		http.Error(w, "status must be success or failure", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("since"); v != "" {
+		t, err := parseActivityDate(v, false)
+		if err != nil {
+			http.Error(w, "Invalid since date", http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+	if v := q.Get("until"); v != "" {
+		t, err := parseActivityDate(v, true)
+		if err != nil {
+			http.Error(w, "Invalid until date", http.StatusBadRequest)
+			return
+		}
+		filter.Until = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxQueryLimit {
+			n = maxQueryLimit
+		}
+		filter.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		filter.Offset = n
+	}
+
+	entries, err := a.git.GetSyncLogs(filter)
+	if err != nil {
+		log.Printf("HandleGetSyncLogs: %v", err)
+		http.Error(w, "Failed to get sync logs", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leraptor65/simple-data-flow/gitops"
+	"github.com/leraptor65/simple-data-flow/watcher"
+)
+
+// HandleGetDeletedFiles lists files that exist in past commits but not in HEAD.
+func (a *API) HandleGetDeletedFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := a.git.GetDeletedFiles()
+	if err != nil {
+		log.Printf("HandleGetDeletedFiles: %v", err)
+		http.Error(w, "Failed to list deleted files", http.StatusInternalServerError)
+		return
+	}
+
+	if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+		filtered := files[:0]
+		for _, f := range files {
+			if strings.HasPrefix(f.Path, prefix) {
+				filtered = append(filtered, f)
+			}
+		}
+		files = filtered
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(files)
+}
+
+// HandleRestoreDeletedFile brings back a file from history. "hash" defaults to
+// the last commit that had the file and "target" to its original path; an
+// existing file at the target is never overwritten.
+func (a *API) HandleRestoreDeletedFile(w http.ResponseWriter, r *http.Request) {
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Path   string `json:"path"`
+		Hash   string `json:"hash"`
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	if req.Target == "" {
+		req.Target = req.Path
+	}
+	req.Path = filepath.ToSlash(filepath.Clean(req.Path))
+	req.Target = filepath.ToSlash(filepath.Clean(req.Target))
+
+	destPath, err := safePath(a.dataDir, req.Target)
+	if err != nil || req.Target == ".git" || strings.HasPrefix(req.Target, ".git/") {
+		http.Error(w, "Invalid target path", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		http.Error(w, "A file already exists at the target path", http.StatusConflict)
+		return
+	}
+
+	if req.Hash == "" {
+		hash, err := a.git.LastSeen(req.Path)
+		if errors.Is(err, gitops.ErrFileNotFound) {
+			http.Error(w, "File not found in history", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("HandleRestoreDeletedFile: %v", err)
+			http.Error(w, "Failed to restore file", http.StatusInternalServerError)
+			return
+		}
+		req.Hash = hash
+	} else if !validGitRevision.MatchString(req.Hash) {
+		http.Error(w, "Invalid hash", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := a.git.RestoreFile(r.Context(), req.Hash, req.Path, req.Target)
+	if errors.Is(err, gitops.ErrFileNotFound) {
+		http.Error(w, "File not found at that commit", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleRestoreDeletedFile: %v", err)
+		http.Error(w, "Failed to restore file", http.StatusInternalServerError)
+		return
+	}
+	watcher.SyncPath(a.db, a.dataDir, req.Target)
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]string{
+		"path":   req.Target,
+		"commit": hash,
+	})
+}
+
+// parseActivityDate accepts a plain date or an RFC3339 timestamp. A plain
+// "until" date covers the whole day.
+func parseActivityDate(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// HandleGetActivity returns the vault-wide timeline: per commit, the notes that
+// were created, modified, moved or deleted. Supports author, folder, since and
+// until filters with limit/offset pagination.
+func (a *API) HandleGetActivity(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := gitops.ActivityFilter{
+		Author: q.Get("author"),
+		Folder: q.Get("folder"),
+		Limit:  defaultQueryLimit,
+	}
+	if filter.Folder != "" {
+		if _, err := safePath(a.dataDir, filter.Folder); err != nil {
+			http.Error(w, "Invalid folder", http.StatusBadRequest)
+			return
+		}
+		filter.Folder = filepath.ToSlash(filepath.Clean(filter.Folder))
+	}
+	if v := q.Get("since"); v != "" {
+		t, err := parseActivityDate(v, false)
+		if err != nil {
+			http.Error(w, "Invalid since date", http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+	if v := q.Get("until"); v != "" {
+		t, err := parseActivityDate(v, true)
+		if err != nil {
+			http.Error(w, "Invalid until date", http.StatusBadRequest)
+			return
+		}
+		filter.Until = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxQueryLimit {
+			n = maxQueryLimit
+		}
+		filter.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		filter.Offset = n
+	}
+
+	entries, hasMore, err := a.git.GetActivity(filter)
+	if err != nil {
+		log.Printf("HandleGetActivity: %v", err)
+		http.Error(w, "Failed to get activity", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"commits":  entries,
+		"limit":    filter.Limit,
+		"offset":   filter.Offset,
+		"has_more": hasMore,
+	})
+}
+
+// HandleGetBlame returns per-line author, commit and date for a note at HEAD.
+func (a *API) HandleGetBlame(w http.ResponseWriter, r *http.Request) {
+	filename := r.URL.Query().Get("file")
+	if filename == "" {
+		http.Error(w, "file parameter is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := safePath(a.dataDir, filename); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	blame, err := a.git.BlameFile(filename)
+	if errors.Is(err, gitops.ErrFileNotFound) {
+		http.Error(w, "File has no committed history", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleGetBlame: %v", err)
+		http.Error(w, "Failed to compute blame", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(blame)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/leraptor65/simple-data-flow/gitops"
+)
+
+// apiToken maps a bearer token to the person commits made with it are
+// attributed to.
+type apiToken struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// identityConfig says where the requesting user comes from. Both sources are
+// optional; without either, commits keep using the configured git identity.
+type identityConfig struct {
+	userHeader  string // e.g. X-Forwarded-User
+	emailHeader string
+	nameHeader  string
+	proxies     []*net.IPNet // peers allowed to set the headers; none means no one
+	tokens      []apiToken
+}
+
+func loadIdentityConfig() identityConfig {
+	cfg := identityConfig{
+		userHeader:  os.Getenv("AUTH_USER_HEADER"),
+		emailHeader: os.Getenv("AUTH_EMAIL_HEADER"),
+		nameHeader:  os.Getenv("AUTH_NAME_HEADER"),
+	}
+
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if strings.Contains(p, ":") {
+				p += "/128"
+			} else {
+				p += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			log.Printf("Ignoring invalid TRUSTED_PROXIES entry %q: %v", p, err)
+			continue
+		}
+		cfg.proxies = append(cfg.proxies, n)
+	}
+
+	if path := os.Getenv("API_TOKENS_FILE"); path != "" {
+		var tokens []apiToken
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Error reading API_TOKENS_FILE: %v", err)
+		} else if err := json.Unmarshal(data, &tokens); err != nil {
+			log.Printf("Error parsing API_TOKENS_FILE: %v", err)
+		}
+		for i, t := range tokens {
+			// An empty token would match a bare "Authorization: Bearer"
+			t.Token = strings.TrimSpace(t.Token)
+			if t.Token == "" {
+				log.Printf("Ignoring API_TOKENS_FILE entry %d (%s): empty token", i, t.Name)
+				continue
+			}
+			cfg.tokens = append(cfg.tokens, t)
+		}
+	}
+
+	if cfg.userHeader != "" && len(cfg.proxies) == 0 {
+		log.Printf("AUTH_USER_HEADER is set but TRUSTED_PROXIES is not; identity headers will be ignored")
+	}
+	return cfg
+}
+
+// trustedPeer reports whether the direct peer may assert identity headers.
+// Only the configured proxies may; anyone else could forge them.
+func (c identityConfig) trustedPeer(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentityMiddleware resolves the requesting user from an API token or, when
+// the request comes through a trusted proxy, from its identity headers, and
+// attaches it to the request context so commits are authored by that user.
+func (a *API) IdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := a.ident
+
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && len(cfg.tokens) > 0 {
+			var match *apiToken
+			bearer = strings.TrimSpace(bearer)
+			for i := range cfg.tokens {
+				if bearer != "" && subtle.ConstantTimeCompare([]byte(cfg.tokens[i].Token), []byte(bearer)) == 1 {
+					match = &cfg.tokens[i]
+					break
+				}
+			}
+			if match == nil {
+				http.Error(w, "Invalid API token", http.StatusUnauthorized)
+				return
+			}
+			ctx := gitops.WithAuthor(r.Context(), gitops.Author{Name: match.Name, Email: match.Email})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if cfg.userHeader != "" && cfg.trustedPeer(r) {
+			user := strings.TrimSpace(r.Header.Get(cfg.userHeader))
+			if user != "" {
+				author := gitops.Author{Name: user}
+				if cfg.nameHeader != "" {
+					if name := strings.TrimSpace(r.Header.Get(cfg.nameHeader)); name != "" {
+						author.Name = name
+					}
+				}
+				if cfg.emailHeader != "" {
+					author.Email = strings.TrimSpace(r.Header.Get(cfg.emailHeader))
+				}
+				if author.Email == "" && strings.Contains(user, "@") {
+					author.Email = user
+				}
+				r = r.WithContext(gitops.WithAuthor(r.Context(), author))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
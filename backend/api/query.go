@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/leraptor65/simple-data-flow/models"
+)
+
+const defaultQueryLimit = 50
+const maxQueryLimit = 500
+
+var validFrontmatterKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// frontmatterFilter is a single `filter=key:op:value` condition from /api/query.
+type frontmatterFilter struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+// parseFrontmatterFilter parses "key:op:value". The value may itself contain
+// colons (e.g. timestamps); exists/missing take no value.
+func parseFrontmatterFilter(raw string) (frontmatterFilter, error) {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) < 2 {
+		return frontmatterFilter{}, fmt.Errorf("filter %q must be key:op[:value]", raw)
+	}
+	f := frontmatterFilter{Key: parts[0], Op: strings.ToLower(parts[1])}
+	if len(parts) == 3 {
+		f.Value = parts[2]
+	}
+	if !validFrontmatterKey.MatchString(f.Key) {
+		return f, fmt.Errorf("invalid property name %q", f.Key)
+	}
+	switch f.Op {
+	case "exists", "missing":
+	case "eq", "ne", "lt", "lte", "gt", "gte", "contains":
+		if len(parts) < 3 {
+			return f, fmt.Errorf("filter %q requires a value", raw)
+		}
+	default:
+		return f, fmt.Errorf("unknown operator %q", f.Op)
+	}
+	return f, nil
+}
+
+// typedFrontmatterValue interprets a query string value the way YAML would,
+// so `priority:eq:3` matches a numeric 3 and `done:eq:true` matches a boolean.
+func typedFrontmatterValue(v string) interface{} {
+	if b, err := strconv.ParseBool(v); err == nil && (v == "true" || v == "false") {
+		return b
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return n
+	}
+	return v
+}
+
+// buildFrontmatterWhere compiles filters into a SQL condition and its arguments.
+func buildFrontmatterWhere(filters []frontmatterFilter) (string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	for _, f := range filters {
+		c, err := frontmatterCondition(f, arg)
+		if err != nil {
+			return "", nil, err
+		}
+		conds = append(conds, c)
+	}
+
+	if len(conds) == 0 {
+		return "TRUE", args, nil
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// frontmatterCondition compiles one filter, binding its values through arg.
+// Equality and list containment use @> and key checks use ? so they can be served
+// by the GIN index on notes.frontmatter.
+func frontmatterCondition(f frontmatterFilter, arg func(interface{}) string) (string, error) {
+	containment := func(key string, v interface{}) (string, error) {
+		data, err := json.Marshal(map[string]interface{}{key: v})
+		if err != nil {
+			return "", err
+		}
+		return "frontmatter @> " + arg(string(data)) + "::jsonb", nil
+	}
+
+	switch f.Op {
+	case "exists":
+		return "frontmatter ? " + arg(f.Key), nil
+	case "missing":
+		return "NOT (COALESCE(frontmatter, '{}') ? " + arg(f.Key) + ")", nil
+	case "eq", "ne":
+		c, err := containment(f.Key, typedFrontmatterValue(f.Value))
+		if err != nil {
+			return "", err
+		}
+		if f.Op == "ne" {
+			c = "NOT COALESCE(" + c + ", false)"
+		}
+		return c, nil
+	case "contains":
+		return containment(f.Key, []interface{}{typedFrontmatterValue(f.Value)})
+	case "lt", "lte", "gt", "gte":
+		op := map[string]string{"lt": "<", "lte": "<=", "gt": ">", "gte": ">="}[f.Op]
+		key := arg(f.Key)
+		// CASE guards the cast so rows holding a different type never raise an error
+		if n, err := strconv.ParseFloat(f.Value, 64); err == nil {
+			return fmt.Sprintf(
+				"(CASE WHEN jsonb_typeof(frontmatter->%s) = 'number' THEN (frontmatter->>%s)::numeric END) %s %s",
+				key, key, op, arg(n)), nil
+		}
+		// Strings, including ISO-8601 dates which order lexically
+		return fmt.Sprintf(
+			"(CASE WHEN jsonb_typeof(frontmatter->%s) = 'string' THEN frontmatter->>%s END) %s %s",
+			key, key, op, arg(f.Value)), nil
+	}
+	return "", fmt.Errorf("unknown operator %q", f.Op)
+}
+
+// HandleQueryNotes filters notes by frontmatter properties.
+//
+//	GET /api/query?filter=status:eq:open&filter=due:lt:2026-01-01&sort=due&order=asc&limit=50&offset=0
+//
+// Operators: eq, ne, lt, lte, gt, gte, contains (list membership), exists, missing.
+// sort accepts "filename", "title", "modified" or any frontmatter key.
+func (a *API) HandleQueryNotes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var filters []frontmatterFilter
+	for _, raw := range q["filter"] {
+		f, err := parseFrontmatterFilter(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filters = append(filters, f)
+	}
+
+	where, args, err := buildFrontmatterWhere(filters)
+	if err != nil {
+		http.Error(w, "Invalid filter", http.StatusBadRequest)
+		return
+	}
+	filterArgs := len(args)
+
+	limit := defaultQueryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxQueryLimit {
+			n = maxQueryLimit
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	direction := "ASC"
+	switch strings.ToLower(q.Get("order")) {
+	case "", "asc":
+	case "desc":
+		direction = "DESC"
+	default:
+		http.Error(w, "Invalid order", http.StatusBadRequest)
+		return
+	}
+
+	orderBy := "filename " + direction
+	switch sortKey := q.Get("sort"); sortKey {
+	case "", "filename":
+	case "title":
+		orderBy = "title " + direction + ", filename ASC"
+	case "modified":
+		orderBy = "last_modified " + direction + ", filename ASC"
+	default:
+		if !validFrontmatterKey.MatchString(sortKey) {
+			http.Error(w, "Invalid sort property", http.StatusBadRequest)
+			return
+		}
+		// jsonb ordering compares numbers numerically and strings lexically
+		args = append(args, sortKey)
+		orderBy = fmt.Sprintf("frontmatter->$%d %s NULLS LAST, filename ASC", len(args), direction)
+	}
+
+	var total int
+	if err := a.db.QueryRow("SELECT COUNT(*) FROM notes WHERE "+where, args[:filterArgs]...).Scan(&total); err != nil {
+		log.Printf("HandleQueryNotes count: %v", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+
+	args = append(args, limit, offset)
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT id, filename, title, COALESCE(frontmatter, '{}'), last_modified
+		FROM notes
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, where, orderBy, len(args)-1, len(args)), args...)
+	if err != nil {
+		log.Printf("HandleQueryNotes: %v", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(&n.ID, &n.Filename, &n.Title, &n.Frontmatter, &n.LastModified); err == nil {
+			notes = append(notes, n)
+		}
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notes":  notes,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leraptor65/simple-data-flow/models"
+)
+
+// smartFolderPrefix starts the tree path of a saved search, keeping it apart
+// from real vault paths.
+const smartFolderPrefix = "saved-search:"
+
+func (a *API) savedSearches() ([]models.SavedSearch, error) {
+	rows, err := a.db.Query("SELECT id, name, query, created_at FROM saved_searches ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		var s models.SavedSearch
+		if err := rows.Scan(&s.ID, &s.Name, &s.Query, &s.CreatedAt); err == nil {
+			searches = append(searches, s)
+		}
+	}
+	return searches, rows.Err()
+}
+
+// smartFolders lists the saved searches as virtual tree folders. They are not
+// evaluated here; the client loads a folder's results when it is opened.
+func (a *API) smartFolders() []*TreeItem {
+	searches, err := a.savedSearches()
+	if err != nil {
+		log.Printf("smartFolders: %v", err)
+		return nil
+	}
+
+	var items []*TreeItem
+	for _, s := range searches {
+		items = append(items, &TreeItem{
+			Name:     s.Name,
+			Path:     smartFolderPrefix + strconv.Itoa(s.ID),
+			Type:     "smart_folder",
+			Query:    s.Query,
+			Children: []*TreeItem{},
+		})
+	}
+	return items
+}
+
+func (a *API) HandleListSavedSearches(w http.ResponseWriter, r *http.Request) {
+	searches, err := a.savedSearches()
+	if err != nil {
+		log.Printf("HandleListSavedSearches: %v", err)
+		http.Error(w, "Failed to list saved searches", http.StatusInternalServerError)
+		return
+	}
+	setJSON(w)
+	json.NewEncoder(w).Encode(searches)
+}
+
+// HandleCreateSavedSearch stores a named query. The query is checked with the
+// search parser so a saved search can always be run.
+//
+//	POST /api/saved-searches {"name": "Open tasks", "query": "tag:task -status:done"}
+func (a *API) HandleCreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Name == "" || req.Query == "" {
+		http.Error(w, "name and query are required", http.StatusBadRequest)
+		return
+	}
+	env, err := a.searchEnv()
+	if err != nil {
+		log.Printf("HandleCreateSavedSearch: %v", err)
+		http.Error(w, "Failed to save search", http.StatusInternalServerError)
+		return
+	}
+	if _, err := compileSearchQuery(req.Query, env); err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var s models.SavedSearch
+	err = a.db.QueryRow(`
+		INSERT INTO saved_searches (name, query) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, name, query, created_at
+	`, req.Name, req.Query).Scan(&s.ID, &s.Name, &s.Query, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "A saved search with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleCreateSavedSearch: %v", err)
+		http.Error(w, "Failed to save search", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+// HandleSavedSearchResults runs a saved search and returns a page of its
+// results like /api/search, without snippets. The total is only counted for
+// the first page.
+//
+//	GET /api/saved-searches/{id}/results?limit=50&cursor=<next_cursor>
+func (a *API) HandleSavedSearchResults(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid saved search id", http.StatusBadRequest)
+		return
+	}
+	limit, cursor, err := parseSearchPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var query string
+	err = a.db.QueryRow("SELECT query FROM saved_searches WHERE id = $1", id).Scan(&query)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Saved search not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleSavedSearchResults: %v", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
+	env, err := a.searchEnv()
+	if err != nil {
+		log.Printf("HandleSavedSearchResults: %v", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+	plan, err := compileSearchQuery(query, env)
+	if err != nil {
+		// Saved before a syntax change
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	plan.tsquery = "" // the tree has no use for snippets
+
+	results, total, next, err := a.runSearch(plan, limit, cursor, cursor == nil)
+	if err != nil {
+		log.Printf("HandleSavedSearchResults: %v", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"results":     results,
+		"limit":       limit,
+		"next_cursor": next,
+	}
+	if cursor == nil {
+		resp["total"] = total
+	}
+	setJSON(w)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (a *API) HandleDeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid saved search id", http.StatusBadRequest)
+		return
+	}
+	res, err := a.db.Exec("DELETE FROM saved_searches WHERE id = $1", id)
+	if err != nil {
+		log.Printf("HandleDeleteSavedSearch: %v", err)
+		http.Error(w, "Failed to delete saved search", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Saved search not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/leraptor65/simple-data-flow/models"
+	"github.com/leraptor65/simple-data-flow/watcher"
+)
+
+// Private-use code points mark highlights in ts_headline output so the snippet
+// can be HTML-escaped before the <mark> tags go in.
+const (
+	snippetStart = "\uE000"
+	snippetStop  = "\uE001"
+)
+
+// snippetOptions configures ts_headline: up to two short fragments per note.
+const snippetOptions = `MaxFragments=2, MaxWords=25, MinWords=8, FragmentDelimiter=" … ", StartSel="` + snippetStart + `", StopSel="` + snippetStop + `"`
+
+// likeEscaper escapes LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchPlan is a compiled search over the notes table. where and score may
+// reference args as $1..$n; tsquery, when set, is the expression snippets are
+// highlighted with.
+type searchPlan struct {
+	where   string
+	score   string
+	tsquery string
+	args    []interface{}
+}
+
+// searchEnv is what compiling a query needs to know about the database.
+type searchEnv struct {
+	fuzzy   bool     // pg_trgm is installed; without it there is no fuzzy matching
+	configs []string // text search configurations notes are indexed with
+}
+
+// searchEnv loads the languages notes may be indexed with: the vault-wide
+// default, the folder settings and any lang: frontmatter.
+func (a *API) searchEnv() (searchEnv, error) {
+	env := searchEnv{fuzzy: a.fuzzy}
+	rows, err := a.db.Query(`
+		SELECT config::text FROM search_languages
+		UNION
+		SELECT search_config::text FROM notes WHERE frontmatter ? 'lang'
+		UNION
+		SELECT $1::text WHERE NOT EXISTS (SELECT 1 FROM search_languages WHERE folder = '')
+		ORDER BY 1
+	`, watcher.DefaultSearchLanguage)
+	if err != nil {
+		return env, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cfg string
+		if err := rows.Scan(&cfg); err != nil {
+			return env, err
+		}
+		env.configs = append(env.configs, cfg)
+	}
+	return env, rows.Err()
+}
+
+// tsQuery parses the query text bound at p with fn (websearch_to_tsquery,
+// plainto_tsquery, ...) once per language and returns a condition matching
+// content_vector and the tsquery to rank and highlight each note with. Each
+// note is matched in the language it was indexed with, and because every
+// tsquery is a constant the GIN index serves each branch.
+func (env searchEnv) tsQuery(fn, p string, arg func(interface{}) string) (match, query string) {
+	var conds, cases []string
+	for _, cfg := range env.configs {
+		c := arg(cfg) + "::regconfig"
+		tsq := fmt.Sprintf("%s(%s, %s)", fn, c, p)
+		conds = append(conds, fmt.Sprintf("(search_config = %s AND content_vector @@ %s)", c, tsq))
+		cases = append(cases, fmt.Sprintf("WHEN %s THEN %s", c, tsq))
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", "CASE search_config " + strings.Join(cases, " ") + " END"
+}
+
+// searchResult is a note (without content) with its relevance and the matched
+// passages, highlighted with <mark>.
+type searchResult struct {
+	models.Note
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchCursor is the position after the last result of a page. Ranks are
+// compared as real, so float32 carries them exactly.
+type searchCursor struct {
+	Rank     float32 `json:"r"`
+	Filename string  `json:"f"`
+}
+
+func (c searchCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(s string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c searchCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// textSearchPlan matches q against the full-text index, the filename and the
+// raw content, and fuzzily through pg_trgm. Relevance comes from ts_rank_cd over
+// the weighted vector (title A, headings B, body D), with a boost for filename
+// matches so a note named after the query comes first. The query is parsed in
+// each note's own language, so it stems the same way the note was indexed.
+func textSearchPlan(q string, env searchEnv) searchPlan {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	text, pattern := arg(q), arg("%"+likeEscaper.Replace(q)+"%")
+	match, tsq := env.tsQuery("websearch_to_tsquery", text, arg)
+
+	p := searchPlan{
+		where:   fmt.Sprintf(`%s OR filename ILIKE %[2]s OR content ILIKE %[2]s`, match, pattern),
+		score:   fmt.Sprintf(`ts_rank_cd(content_vector, %s, 32) + CASE WHEN filename ILIKE %s THEN 1 ELSE 0 END`, tsq, pattern),
+		tsquery: tsq,
+	}
+	if env.fuzzy {
+		p.where += " OR " + fuzzyMatch(text)
+		p.score += " + " + fuzzyScore(text)
+	}
+	p.args = args
+	return p
+}
+
+// fuzzyMatch matches the query bound at placeholder p against the filename,
+// title and content by trigram word similarity, so typos ("meetng") and partial
+// words still match in any script. The <% operator is served by the
+// gin_trgm_ops indexes and uses pg_trgm.word_similarity_threshold (0.6 by
+// default).
+func fuzzyMatch(p string) string {
+	return fmt.Sprintf("(%[1]s <%% filename OR %[1]s <%% title OR %[1]s <%% content)", p)
+}
+
+// fuzzyScore ranks fuzzy matches by how closely the filename or title, and to
+// a lesser degree the content, resemble the query bound at p.
+func fuzzyScore(p string) string {
+	return fmt.Sprintf("GREATEST(word_similarity(%[1]s, filename), word_similarity(%[1]s, COALESCE(title, ''))) * 0.5 + "+
+		"word_similarity(%[1]s, COALESCE(content, '')) * 0.25", p)
+}
+
+// runSearch returns one page of results after the cursor, the total number of
+// matches and the cursor for the next page ("" on the last one). Counting costs
+// a second pass over the matches, so without count the total is left at 0.
+func (a *API) runSearch(p searchPlan, limit int, after *searchCursor, count bool) ([]searchResult, int, string, error) {
+	var total int
+	if count {
+		// The score is selected so every bound argument is referenced; the planner
+		// drops the unused column, so it is never computed
+		if err := a.db.QueryRow("SELECT COUNT(*) FROM (SELECT ("+p.score+") AS score FROM notes WHERE ("+p.where+")) matched", p.args...).Scan(&total); err != nil {
+			return nil, 0, "", err
+		}
+	}
+
+	args := append([]interface{}{}, p.args...)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	position := "TRUE"
+	if after != nil {
+		rank, filename := arg(after.Rank), arg(after.Filename)
+		position = fmt.Sprintf("(score < %[1]s::real OR (score = %[1]s::real AND filename > %[2]s))", rank, filename)
+	}
+	snippet := "''"
+	if p.tsquery != "" {
+		snippet = fmt.Sprintf("ts_headline(search_config, content, %s, %s)", p.tsquery, arg(snippetOptions))
+	}
+
+	// Snippets are computed in the outer query so only the page is highlighted
+	rows, err := a.db.Query(fmt.Sprintf(`
+		SELECT id, filename, title, frontmatter, last_modified, score, %s
+		FROM (
+			SELECT * FROM (
+				SELECT id, filename, title, COALESCE(frontmatter, '{}') AS frontmatter, last_modified, content, search_config,
+					(%s)::real AS score
+				FROM notes
+				WHERE (%s)
+			) matched
+			WHERE %s
+			ORDER BY score DESC, filename ASC
+			LIMIT %s
+		) page
+		ORDER BY score DESC, filename ASC
+	`, snippet, p.score, p.where, position, arg(limit+1)), args...)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer rows.Close()
+
+	results := []searchResult{}
+	for rows.Next() {
+		var res searchResult
+		if err := rows.Scan(&res.ID, &res.Filename, &res.Title, &res.Frontmatter, &res.LastModified, &res.Rank, &res.Snippet); err != nil {
+			return nil, 0, "", err
+		}
+		res.Snippet = highlightSnippet(res.Snippet)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, "", err
+	}
+
+	next := ""
+	if len(results) > limit {
+		results = results[:limit]
+		last := results[limit-1]
+		next = searchCursor{Rank: last.Rank, Filename: last.Filename}.encode()
+	}
+	return results, total, next, nil
+}
+
+// highlightSnippet escapes ts_headline output for HTML and turns the highlight
+// markers into <mark> tags.
+func highlightSnippet(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = html.EscapeString(s)
+	return strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>").Replace(s)
+}
+
+// parseSearchPage reads limit and cursor from a search request.
+func parseSearchPage(r *http.Request) (int, *searchCursor, error) {
+	q := r.URL.Query()
+	limit := defaultQueryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, nil, errors.New("Invalid limit")
+		}
+		if n > maxQueryLimit {
+			n = maxQueryLimit
+		}
+		limit = n
+	}
+	var cursor *searchCursor
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeSearchCursor(v)
+		if err != nil {
+			return 0, nil, errors.New("Invalid cursor")
+		}
+		cursor = c
+	}
+	return limit, cursor, nil
+}
+
+// HandleSearchNotes ranks notes against a search query.
+//
+//	GET /api/search?q=release+notes+-draft+tag:work&limit=20&cursor=<next_cursor>
+//
+// See parseSearchQuery for the query language; "#tag" is short for tag:tag.
+// Results come with a rank and a highlighted snippet; pass next_cursor back to
+// get the next page.
+func (a *API) HandleSearchNotes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
+		return
+	}
+	limit, cursor, err := parseSearchPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	env, err := a.searchEnv()
+	if err != nil {
+		log.Printf("HandleSearchNotes: %v", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+	plan, err := compileSearchQuery(query, env)
+	if err != nil {
+		http.Error(w, "Invalid query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, total, next, err := a.runSearch(plan, limit, cursor, true)
+	if err != nil {
+		log.Printf("HandleSearchNotes: %v", err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":     results,
+		"total":       total,
+		"limit":       limit,
+		"next_cursor": next,
+	})
+}
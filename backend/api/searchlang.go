@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/leraptor65/simple-data-flow/watcher"
+)
+
+// folderLanguage is a per-folder text search language.
+type folderLanguage struct {
+	Folder   string `json:"folder"`
+	Language string `json:"language"`
+}
+
+// cleanFolder validates a vault folder and returns it in slash form without
+// leading or trailing slashes.
+func (a *API) cleanFolder(folder string) (string, bool) {
+	if _, err := safePath(a.dataDir, folder); err != nil {
+		return "", false
+	}
+	folder = strings.Trim(filepath.ToSlash(filepath.Clean(folder)), "/")
+	return folder, folder != "" && folder != "."
+}
+
+// HandleGetSearchLanguages lists the vault-wide text search language, the
+// per-folder overrides and the configurations the database has installed.
+// Notes can also pick their own with a lang: frontmatter field.
+func (a *API) HandleGetSearchLanguages(w http.ResponseWriter, r *http.Request) {
+	var defaultLang string
+	if err := a.db.QueryRow("SELECT config::text FROM search_languages WHERE folder = ''").Scan(&defaultLang); err != nil {
+		defaultLang = "english"
+	}
+
+	rows, err := a.db.Query("SELECT folder, config::text FROM search_languages WHERE folder <> '' ORDER BY folder")
+	if err != nil {
+		log.Printf("HandleGetSearchLanguages: %v", err)
+		http.Error(w, "Failed to list search languages", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	folders := []folderLanguage{}
+	for rows.Next() {
+		var f folderLanguage
+		if err := rows.Scan(&f.Folder, &f.Language); err == nil {
+			folders = append(folders, f)
+		}
+	}
+
+	available := []string{}
+	cfgRows, err := a.db.Query("SELECT cfgname FROM pg_ts_config ORDER BY cfgname")
+	if err != nil {
+		log.Printf("HandleGetSearchLanguages: %v", err)
+		http.Error(w, "Failed to list search languages", http.StatusInternalServerError)
+		return
+	}
+	defer cfgRows.Close()
+	for cfgRows.Next() {
+		var name string
+		if err := cfgRows.Scan(&name); err == nil {
+			available = append(available, name)
+		}
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"default":   defaultLang,
+		"folders":   folders,
+		"available": available,
+	})
+}
+
+// HandleSetFolderLanguage sets the text search language for every note in a
+// folder (and its subfolders) without a lang: of its own, then re-indexes them.
+//
+//	PUT /api/search/languages {"folder": "journal/de", "language": "de"}
+func (a *API) HandleSetFolderLanguage(w http.ResponseWriter, r *http.Request) {
+	limitBody(r, maxJSONBodySize)
+	var req folderLanguage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	folder, ok := a.cleanFolder(req.Folder)
+	if !ok {
+		http.Error(w, "Invalid folder", http.StatusBadRequest)
+		return
+	}
+	cfg, err := watcher.SearchConfig(a.db, req.Language)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := a.db.Exec(`
+		INSERT INTO search_languages (folder, config) VALUES ($1, $2::regconfig)
+		ON CONFLICT (folder) DO UPDATE SET config = EXCLUDED.config
+	`, folder, cfg); err != nil {
+		log.Printf("HandleSetFolderLanguage: %v", err)
+		http.Error(w, "Failed to set search language", http.StatusInternalServerError)
+		return
+	}
+	watcher.ReindexPath(a.db, a.dataDir, folder)
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(folderLanguage{Folder: folder, Language: cfg})
+}
+
+// HandleDeleteFolderLanguage removes a folder's override so its notes fall back
+// to the parent folder or the vault-wide language.
+//
+//	DELETE /api/search/languages?folder=journal/de
+func (a *API) HandleDeleteFolderLanguage(w http.ResponseWriter, r *http.Request) {
+	folder, ok := a.cleanFolder(r.URL.Query().Get("folder"))
+	if !ok {
+		http.Error(w, "Invalid folder", http.StatusBadRequest)
+		return
+	}
+	res, err := a.db.Exec("DELETE FROM search_languages WHERE folder = $1", folder)
+	if err != nil {
+		log.Printf("HandleDeleteFolderLanguage: %v", err)
+		http.Error(w, "Failed to remove search language", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "No search language set for this folder", http.StatusNotFound)
+		return
+	}
+	watcher.ReindexPath(a.db, a.dataDir, folder)
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,383 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchFieldKey is what may precede ':' in a field term. Requiring a leading
+// letter keeps times like 12:30 as plain text.
+var searchFieldKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// searchOperators are the built-in fields; any other key:value term filters on
+// frontmatter.
+var searchOperators = map[string]bool{
+	"path": true, "folder": true, "tag": true, "title": true, "modified": true,
+	"has": true, "links-to": true, "linked-from": true,
+}
+
+// searchTerm is one term of a parsed query. field is "" for free text, a
+// lowercased operator name, or a frontmatter key.
+type searchTerm struct {
+	field  string
+	value  string
+	quoted bool
+	negate bool
+	or     bool // the OR keyword, only seen while parsing
+	pos    int
+}
+
+// searchClause is a group of terms joined by OR. A query matches when every
+// clause does.
+type searchClause []searchTerm
+
+// parseSearchQuery parses the /api/search query language:
+//
+//	word "exact phrase" -excluded a OR b
+//	path:daily/ folder:projects tag:work title:plan status:done priority:>2
+//	modified:>2026-01-01 has:image links-to:roadmap linked-from:index
+//
+// Terms are ANDed; OR binds the terms on either side of it. Any term can be
+// negated with a leading '-', and field values can be quoted (title:"q3 plan").
+func parseSearchQuery(q string) ([]searchClause, error) {
+	terms, err := tokenizeSearch(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var clauses []searchClause
+	pendingOr := -1
+	for _, t := range terms {
+		if t.or {
+			if len(clauses) == 0 || pendingOr >= 0 {
+				return nil, fmt.Errorf("OR at position %d must sit between two terms", column(q, t.pos))
+			}
+			pendingOr = t.pos
+			continue
+		}
+		if pendingOr >= 0 {
+			clauses[len(clauses)-1] = append(clauses[len(clauses)-1], t)
+			pendingOr = -1
+		} else {
+			clauses = append(clauses, searchClause{t})
+		}
+	}
+	if pendingOr >= 0 {
+		return nil, fmt.Errorf("OR at position %d must sit between two terms", column(q, pendingOr))
+	}
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+	return clauses, nil
+}
+
+// tokenizeSearch splits q into terms, resolving quotes, negation and fields.
+func tokenizeSearch(q string) ([]searchTerm, error) {
+	var terms []searchTerm
+	i := 0
+	for i < len(q) {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		t := searchTerm{pos: i}
+		if q[i] == '-' && i+1 < len(q) {
+			if next, _ := utf8.DecodeRuneInString(q[i+1:]); !unicode.IsSpace(next) {
+				t.negate = true
+				i++
+			}
+		}
+
+		// "a phrase"
+		if q[i] == '"' {
+			value, end, err := readQuoted(q, i)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(value) == "" {
+				return nil, fmt.Errorf("empty phrase at position %d", column(q, i))
+			}
+			t.value, t.quoted = value, true
+			terms = append(terms, t)
+			i = end
+			continue
+		}
+
+		// A bare word, or field:value where the value may be quoted
+		start := i
+		quotedValue, hasQuotedValue := "", false
+		for i < len(q) {
+			r, size := utf8.DecodeRuneInString(q[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			if r == '"' {
+				if i == start || q[i-1] != ':' {
+					return nil, fmt.Errorf("unexpected quote at position %d", column(q, i))
+				}
+				value, end, err := readQuoted(q, i)
+				if err != nil {
+					return nil, err
+				}
+				quotedValue, hasQuotedValue = value, true
+				i = end
+				break
+			}
+			i += size
+		}
+		word := q[start:i]
+		if hasQuotedValue {
+			word = strings.TrimSuffix(q[start:i], `"`+quotedValue+`"`)
+		}
+
+		if word == "OR" && !t.negate && !hasQuotedValue {
+			t.or = true
+			terms = append(terms, t)
+			continue
+		}
+
+		if key, value, ok := strings.Cut(word, ":"); ok && searchFieldKey.MatchString(key) && !strings.HasPrefix(value, "//") {
+			if searchOperators[strings.ToLower(key)] {
+				key = strings.ToLower(key)
+			}
+			if hasQuotedValue {
+				value, t.quoted = quotedValue, true
+			}
+			if strings.TrimSpace(value) == "" {
+				return nil, fmt.Errorf("%s: at position %d needs a value", key, column(q, start))
+			}
+			t.field, t.value = key, value
+		} else if hasQuotedValue {
+			return nil, fmt.Errorf("unexpected quote at position %d", column(q, start+len(word)))
+		} else if strings.HasPrefix(word, "#") && len(word) > 1 {
+			t.field, t.value = "tag", word[1:]
+		} else {
+			t.value = word
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// readQuoted reads the quoted string opening at q[i] and returns its content
+// and the index after the closing quote.
+func readQuoted(q string, i int) (string, int, error) {
+	end := strings.IndexByte(q[i+1:], '"')
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated quote at position %d", column(q, i))
+	}
+	return q[i+1 : i+1+end], i + end + 2, nil
+}
+
+// column turns a byte offset into a 1-based character position for errors.
+func column(q string, offset int) int {
+	return utf8.RuneCountInString(q[:offset]) + 1
+}
+
+// isPlainSearch reports whether the query is nothing but bare words, which are
+// searched as a whole with textSearchPlan.
+func isPlainSearch(clauses []searchClause) bool {
+	for _, c := range clauses {
+		if len(c) > 1 || c[0].field != "" || c[0].quoted || c[0].negate {
+			return false
+		}
+	}
+	return true
+}
+
+// compileSearchQuery parses q and compiles it into a searchPlan. User input
+// only ever reaches the database as bound parameters.
+func compileSearchQuery(q string, env searchEnv) (searchPlan, error) {
+	clauses, err := parseSearchQuery(q)
+	if err != nil {
+		return searchPlan{}, err
+	}
+	if isPlainSearch(clauses) {
+		return textSearchPlan(q, env), nil
+	}
+
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conds, rankTerms, boosts []string
+	for _, c := range clauses {
+		var alternatives []string
+		for _, t := range c {
+			cond, err := compileSearchTerm(t, env, arg)
+			if err != nil {
+				return searchPlan{}, err
+			}
+			if t.negate {
+				// Rows where the condition is NULL (e.g. a missing property) count as not matching
+				cond = "NOT COALESCE(" + cond + ", FALSE)"
+			} else if t.field == "" {
+				rank := t.value
+				if t.quoted {
+					rank = `"` + rank + `"`
+				}
+				rankTerms = append(rankTerms, rank)
+				boosts = append(boosts,
+					fmt.Sprintf("CASE WHEN filename ILIKE %s THEN 1 ELSE 0 END", arg("%"+likeEscaper.Replace(t.value)+"%")))
+				if env.fuzzy {
+					boosts = append(boosts, fuzzyScore(arg(t.value)))
+				}
+			}
+			alternatives = append(alternatives, cond)
+		}
+		conds = append(conds, "("+strings.Join(alternatives, " OR ")+")")
+	}
+
+	plan := searchPlan{where: strings.Join(conds, " AND "), score: "0"}
+	if len(rankTerms) > 0 {
+		_, tsq := env.tsQuery("websearch_to_tsquery", arg(strings.Join(rankTerms, " or ")), arg)
+		plan.score = fmt.Sprintf("ts_rank_cd(content_vector, %s, 32) + %s", tsq, strings.Join(boosts, " + "))
+		plan.tsquery = tsq
+	}
+	plan.args = args
+	return plan, nil
+}
+
+// compileSearchTerm compiles one term. Negation is applied by the caller.
+func compileSearchTerm(t searchTerm, env searchEnv, arg func(interface{}) string) (string, error) {
+	contains := func(s string) string {
+		return arg("%" + likeEscaper.Replace(s) + "%")
+	}
+
+	switch t.field {
+	case "":
+		pattern, value := contains(t.value), arg(t.value)
+		if t.quoted {
+			match, _ := env.tsQuery("phraseto_tsquery", value, arg)
+			return fmt.Sprintf("(%s OR filename ILIKE %s OR content ILIKE %s)", match, pattern, pattern), nil
+		}
+		match, _ := env.tsQuery("plainto_tsquery", value, arg)
+		cond := fmt.Sprintf("%s OR filename ILIKE %s OR content ILIKE %s", match, pattern, pattern)
+		// Exclusions stay exact so -draft does not also drop notes that merely resemble it
+		if env.fuzzy && !t.negate {
+			cond += " OR " + fuzzyMatch(value)
+		}
+		return "(" + cond + ")", nil
+
+	case "path":
+		return "filename ILIKE " + contains(t.value), nil
+
+	case "folder":
+		folder := strings.Trim(t.value, "/")
+		if folder == "" {
+			return "filename NOT LIKE '%/%'", nil
+		}
+		return "filename LIKE " + arg(likeEscaper.Replace(folder)+"/%"), nil
+
+	case "title":
+		return "title ILIKE " + contains(t.value), nil
+
+	case "tag":
+		tag := strings.ToLower(strings.Trim(strings.TrimPrefix(t.value, "#"), "/"))
+		if tag == "" {
+			return "", fmt.Errorf("tag: needs a tag name, got %q", t.value)
+		}
+		return fmt.Sprintf("id IN (SELECT note_id FROM note_tags WHERE tag = %s OR tag LIKE %s)",
+			arg(tag), arg(likeEscaper.Replace(tag)+"/%")), nil
+
+	case "modified":
+		return modifiedCondition(t.value, arg)
+
+	case "has":
+		switch strings.ToLower(t.value) {
+		case "image":
+			return `content ~* '!\[[^]]*\]\(|<img[[:space:]]'`, nil
+		case "link":
+			return "EXISTS (SELECT 1 FROM links WHERE links.source_id = notes.id)", nil
+		case "backlink":
+			return "EXISTS (SELECT 1 FROM links WHERE links.target_id = notes.id)", nil
+		case "tag":
+			return "EXISTS (SELECT 1 FROM note_tags WHERE note_tags.note_id = notes.id)", nil
+		}
+		return "", fmt.Errorf("has: accepts image, link, backlink or tag, got %q", t.value)
+
+	case "links-to":
+		return fmt.Sprintf("id IN (SELECT l.source_id FROM links l JOIN notes t ON t.id = l.target_id WHERE %s)",
+			noteRefCondition("t", t.value, arg)), nil
+
+	case "linked-from":
+		return fmt.Sprintf("id IN (SELECT l.target_id FROM links l JOIN notes s ON s.id = l.source_id WHERE %s)",
+			noteRefCondition("s", t.value, arg)), nil
+	}
+
+	return propertyCondition(t.field, t.value, arg)
+}
+
+// noteRefCondition matches the note a link term names the way wiki links are
+// resolved: by path, by file name in any folder, or by title.
+func noteRefCondition(alias, ref string, arg func(interface{}) string) string {
+	name := strings.TrimSuffix(strings.Trim(ref, "/"), ".md")
+	return fmt.Sprintf("(%[1]s.filename = %[2]s OR %[1]s.filename LIKE %[3]s OR lower(%[1]s.title) = lower(%[4]s))",
+		alias, arg(name+".md"), arg("%/"+likeEscaper.Replace(name)+".md"), arg(name))
+}
+
+// propertyCondition compiles key:value against frontmatter. The value may start
+// with a comparison (priority:>2, due:<=2026-03-01); key:* requires the key to
+// be present. A plain value matches the property itself or an item of a list.
+func propertyCondition(key, value string, arg func(interface{}) string) (string, error) {
+	if value == "*" {
+		return frontmatterCondition(frontmatterFilter{Key: key, Op: "exists"}, arg)
+	}
+	for _, c := range []struct{ prefix, op string }{{">=", "gte"}, {"<=", "lte"}, {">", "gt"}, {"<", "lt"}} {
+		if rest, ok := strings.CutPrefix(value, c.prefix); ok {
+			if rest == "" {
+				return "", fmt.Errorf("%s:%s needs a value to compare with", key, c.prefix)
+			}
+			return frontmatterCondition(frontmatterFilter{Key: key, Op: c.op, Value: rest}, arg)
+		}
+	}
+	eq, err := frontmatterCondition(frontmatterFilter{Key: key, Op: "eq", Value: value}, arg)
+	if err != nil {
+		return "", err
+	}
+	item, err := frontmatterCondition(frontmatterFilter{Key: key, Op: "contains", Value: value}, arg)
+	if err != nil {
+		return "", err
+	}
+	return "(" + eq + " OR " + item + ")", nil
+}
+
+// modifiedCondition compiles modified:[op]date. A bare date covers the whole
+// day, so modified:>2026-01-01 starts on January 2nd.
+func modifiedCondition(value string, arg func(interface{}) string) (string, error) {
+	op := "="
+	for _, p := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(value, p); ok {
+			op, value = p, rest
+			break
+		}
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return fmt.Sprintf("last_modified %s %s", op, arg(t.UTC())), nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return "", fmt.Errorf("modified: expects a date like 2026-01-01 or an RFC3339 time, got %q", value)
+	}
+	next := day.AddDate(0, 0, 1)
+	switch op {
+	case ">":
+		return "last_modified >= " + arg(next), nil
+	case ">=":
+		return "last_modified >= " + arg(day), nil
+	case "<":
+		return "last_modified < " + arg(day), nil
+	case "<=":
+		return "last_modified < " + arg(next), nil
+	}
+	return fmt.Sprintf("(last_modified >= %s AND last_modified < %s)", arg(day), arg(next)), nil
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []searchClause
+		wantErr string
+	}{
+		{
+			query: "a OR b c",
+			want: []searchClause{
+				{{value: "a", pos: 0}, {value: "b", pos: 5}},
+				{{value: "c", pos: 7}},
+			},
+		},
+		{
+			query: `-"draft notes"`,
+			want:  []searchClause{{{value: "draft notes", quoted: true, negate: true}}},
+		},
+		{
+			query: `title:"q3 plan" -Status:done`,
+			want: []searchClause{
+				{{field: "title", value: "q3 plan", quoted: true}},
+				{{field: "Status", value: "done", negate: true, pos: 16}},
+			},
+		},
+		{
+			query: "meet at 12:30",
+			want: []searchClause{
+				{{value: "meet"}},
+				{{value: "at", pos: 5}},
+				{{value: "12:30", pos: 8}},
+			},
+		},
+		{
+			query: "https://example.com #work",
+			want: []searchClause{
+				{{value: "https://example.com"}},
+				{{field: "tag", value: "work", pos: 20}},
+			},
+		},
+		{query: "a OR", wantErr: "OR at position 3 must sit between two terms"},
+		{query: "OR a", wantErr: "OR at position 1 must sit between two terms"},
+		{query: "a OR OR b", wantErr: "OR at position 6 must sit between two terms"},
+		{query: `"open`, wantErr: "unterminated quote at position 1"},
+		{query: `title:"open`, wantErr: "unterminated quote at position 7"},
+		{query: `ab"c`, wantErr: "unexpected quote at position 3"},
+		{query: `""`, wantErr: "empty phrase at position 1"},
+		{query: "tag:", wantErr: "tag: at position 1 needs a value"},
+		{query: "   ", wantErr: "query is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseSearchQuery(tt.query)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSearchQuery(t *testing.T) {
+	env := searchEnv{configs: []string{"english"}}
+	day := func(d int) time.Time { return time.Date(2026, time.January, d, 0, 0, 0, 0, time.UTC) }
+	text := func(n int) string {
+		return fmt.Sprintf("((search_config = $%[3]d::regconfig AND content_vector @@ plainto_tsquery($%[3]d::regconfig, $%[2]d))) OR filename ILIKE $%[1]d OR content ILIKE $%[1]d", n, n+1, n+2)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		where   string
+		args    []interface{}
+		wantErr string
+	}{
+		{
+			name:  "OR binds its neighbours",
+			query: "tag:a OR tag:b path:x",
+			where: "(id IN (SELECT note_id FROM note_tags WHERE tag = $1 OR tag LIKE $2) OR id IN (SELECT note_id FROM note_tags WHERE tag = $3 OR tag LIKE $4)) AND (filename ILIKE $5)",
+			args:  []interface{}{"a", "a/%", "b", "b/%", "%x%"},
+		},
+		{
+			name:  "negated phrase",
+			query: `-"draft notes"`,
+			where: "(NOT COALESCE((((search_config = $3::regconfig AND content_vector @@ phraseto_tsquery($3::regconfig, $2))) OR filename ILIKE $1 OR content ILIKE $1), FALSE))",
+			args:  []interface{}{"%draft notes%", "draft notes", "english"},
+		},
+		{
+			name:  "negated word is not fuzzy",
+			query: "-draft",
+			where: "(NOT COALESCE((" + text(1) + "), FALSE))",
+			args:  []interface{}{"%draft%", "draft", "english"},
+		},
+		{
+			name:  "quoted field value",
+			query: `title:"q3 plan"`,
+			where: "(title ILIKE $1)",
+			args:  []interface{}{"%q3 plan%"},
+		},
+		{
+			name:  "quoted field value escapes LIKE wildcards",
+			query: `path:"50%_off"`,
+			where: "(filename ILIKE $1)",
+			args:  []interface{}{`%50\%\_off%`},
+		},
+		{
+			name:  "modified after a day starts the next day",
+			query: "modified:>2026-01-01",
+			where: "(last_modified >= $1)",
+			args:  []interface{}{day(2)},
+		},
+		{
+			name:  "modified from a day includes it",
+			query: "modified:>=2026-01-01",
+			where: "(last_modified >= $1)",
+			args:  []interface{}{day(1)},
+		},
+		{
+			name:  "modified before a day excludes it",
+			query: "modified:<2026-01-01",
+			where: "(last_modified < $1)",
+			args:  []interface{}{day(1)},
+		},
+		{
+			name:  "modified up to a day includes it",
+			query: "modified:<=2026-01-01",
+			where: "(last_modified < $1)",
+			args:  []interface{}{day(2)},
+		},
+		{
+			name:  "modified on a day covers the whole day",
+			query: "modified:2026-01-01",
+			where: "((last_modified >= $1 AND last_modified < $2))",
+			args:  []interface{}{day(1), day(2)},
+		},
+		{
+			name:  "modified at an exact time",
+			query: "modified:>2026-01-01T10:00:00Z",
+			where: "(last_modified > $1)",
+			args:  []interface{}{time.Date(2026, time.January, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "time stays free text",
+			query: "-draft 12:30",
+			where: "(NOT COALESCE((" + text(1) + "), FALSE)) AND ((" + text(4) + "))",
+			args:  []interface{}{"%draft%", "draft", "english", "%12:30%", "12:30", "english", "%12:30%", "12:30", "english"},
+		},
+		{
+			name:  "plain words search as a whole",
+			query: "meet at 12:30",
+			where: "((search_config = $3::regconfig AND content_vector @@ websearch_to_tsquery($3::regconfig, $1))) OR filename ILIKE $2 OR content ILIKE $2",
+			args:  []interface{}{"meet at 12:30", "%meet at 12:30%", "english"},
+		},
+		{name: "bad date", query: "modified:yesterday", wantErr: `modified: expects a date like 2026-01-01 or an RFC3339 time, got "yesterday"`},
+		{name: "bad has", query: "has:video", wantErr: `has: accepts image, link, backlink or tag, got "video"`},
+		{name: "unterminated quote", query: `tag:"work`, wantErr: "unterminated quote at position 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := compileSearchQuery(tt.query, env)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if plan.where != tt.where {
+				t.Errorf("where:\n got %s\nwant %s", plan.where, tt.where)
+			}
+			if !reflect.DeepEqual(plan.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", plan.args, tt.args)
+			}
+		})
+	}
+}
+
+func TestCompileSearchQueryLanguagesAndFuzzy(t *testing.T) {
+	plan, err := compileSearchQuery("meetng notes", searchEnv{fuzzy: true, configs: []string{"english", "german"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One constant tsquery per language keeps every branch indexable
+	for _, want := range []string{
+		"(search_config = $3::regconfig AND content_vector @@ websearch_to_tsquery($3::regconfig, $1))",
+		"(search_config = $4::regconfig AND content_vector @@ websearch_to_tsquery($4::regconfig, $1))",
+		"($1 <% filename OR $1 <% title OR $1 <% content)",
+	} {
+		if !strings.Contains(plan.where, want) {
+			t.Errorf("where lacks %s:\n%s", want, plan.where)
+		}
+	}
+	if strings.Contains(plan.where, "(search_config,") {
+		t.Errorf("where parses the query per row:\n%s", plan.where)
+	}
+	wantTsquery := "CASE search_config WHEN $3::regconfig THEN websearch_to_tsquery($3::regconfig, $1) WHEN $4::regconfig THEN websearch_to_tsquery($4::regconfig, $1) END"
+	if plan.tsquery != wantTsquery {
+		t.Errorf("tsquery = %s, want %s", plan.tsquery, wantTsquery)
+	}
+	wantArgs := []interface{}{"meetng notes", "%meetng notes%", "english", "german"}
+	if !reflect.DeepEqual(plan.args, wantArgs) {
+		t.Errorf("args = %#v, want %#v", plan.args, wantArgs)
+	}
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leraptor65/simple-data-flow/gitops"
+	"github.com/leraptor65/simple-data-flow/models"
+	"github.com/leraptor65/simple-data-flow/watcher"
+)
+
+func (a *API) HandleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.Contains(req.Name, "\n") || !gitops.ValidSnapshotID(gitops.SnapshotID(req.Name)) {
+		http.Error(w, "A snapshot name with at least one letter or digit is required", http.StatusBadRequest)
+		return
+	}
+
+	snap, err := a.git.CreateSnapshot(r.Context(), req.Name, req.Message)
+	if errors.Is(err, gitops.ErrSnapshotExists) {
+		http.Error(w, "A snapshot with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleCreateSnapshot: %v", err)
+		http.Error(w, "Failed to create snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snap)
+}
+
+func (a *API) HandleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := a.git.ListSnapshots()
+	if err != nil {
+		log.Printf("HandleListSnapshots: %v", err)
+		http.Error(w, "Failed to list snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// snapshotID reads and validates the {id} URL parameter.
+func snapshotID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := chi.URLParam(r, "id")
+	if !gitops.ValidSnapshotID(id) {
+		http.Error(w, "Invalid snapshot id", http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+// HandleGetSnapshotTree returns the note tree as it was at a snapshot, in the
+// same shape as GET /api/tree. An optional ?path= limits it to one folder.
+func (a *API) HandleGetSnapshotTree(w http.ResponseWriter, r *http.Request) {
+	id, ok := snapshotID(w, r)
+	if !ok {
+		return
+	}
+	folder := r.URL.Query().Get("path")
+	if folder != "" {
+		if _, err := safePath(a.dataDir, folder); err != nil {
+			http.Error(w, "Invalid path", http.StatusBadRequest)
+			return
+		}
+	}
+
+	snap, files, err := a.git.SnapshotFiles(id, folder)
+	if errors.Is(err, gitops.ErrSnapshotNotFound) {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleGetSnapshotTree: %v", err)
+		http.Error(w, "Failed to get snapshot tree", http.StatusInternalServerError)
+		return
+	}
+
+	root := &TreeItem{Name: "root", Type: "folder", Children: []*TreeItem{}}
+	modified := snap.Date.Format("2006-01-02T15:04:05Z07:00")
+	sort.Strings(files)
+	for _, file := range files {
+		// Mirror HandleGetTree: markdown only, no hidden entries, recycle bin or images
+		if !strings.HasSuffix(file, ".md") || strings.HasPrefix(file, "images/") {
+			continue
+		}
+		parts := strings.Split(file, "/")
+		hidden := false
+		for _, part := range parts {
+			if strings.HasPrefix(part, ".") {
+				hidden = true
+				break
+			}
+		}
+		if hidden {
+			continue
+		}
+
+		current := root
+		for i := range parts {
+			var found *TreeItem
+			for _, child := range current.Children {
+				if child.Name == parts[i] {
+					found = child
+					break
+				}
+			}
+			if found == nil {
+				itemType := "folder"
+				if i == len(parts)-1 {
+					itemType = "file"
+				}
+				found = &TreeItem{
+					Name:         parts[i],
+					Path:         strings.Join(parts[:i+1], "/"),
+					Type:         itemType,
+					LastModified: modified,
+					Children:     []*TreeItem{},
+				}
+				current.Children = append(current.Children, found)
+			}
+			current = found
+		}
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(root.Children)
+}
+
+// HandleGetSnapshotNote returns a note's content as it was at a snapshot.
+func (a *API) HandleGetSnapshotNote(w http.ResponseWriter, r *http.Request) {
+	id, ok := snapshotID(w, r)
+	if !ok {
+		return
+	}
+	filename, _ := url.PathUnescape(chi.URLParam(r, "*"))
+	if _, err := safePath(a.dataDir, filename); err != nil || filename == "" {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	content, err := a.git.SnapshotFileContent(id, filename)
+	if errors.Is(err, gitops.ErrSnapshotNotFound) {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, gitops.ErrFileNotFound) {
+		http.Error(w, "Note not found in snapshot", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleGetSnapshotNote: %v", err)
+		http.Error(w, "Failed to get note", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(models.Note{
+		Filename: filename,
+		Title:    filename,
+		Content:  content,
+	})
+}
+
+// HandleRestoreSnapshot brings a folder, or the whole vault when no folder is
+// given, back to a snapshot as a new commit.
+func (a *API) HandleRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	id, ok := snapshotID(w, r)
+	if !ok {
+		return
+	}
+	limitBody(r, maxJSONBodySize)
+	var req struct {
+		Folder string `json:"folder"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Folder != "" {
+		if _, err := safePath(a.dataDir, req.Folder); err != nil {
+			http.Error(w, "Invalid folder", http.StatusBadRequest)
+			return
+		}
+		req.Folder = filepath.ToSlash(filepath.Clean(req.Folder))
+	}
+
+	hash, err := a.git.RestoreSnapshot(r.Context(), id, req.Folder)
+	if errors.Is(err, gitops.ErrSnapshotNotFound) {
+		http.Error(w, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("HandleRestoreSnapshot: %v", err)
+		http.Error(w, "Failed to restore snapshot", http.StatusInternalServerError)
+		return
+	}
+	if req.Folder != "" {
+		watcher.SyncPath(a.db, a.dataDir, req.Folder)
+	} else {
+		watcher.SyncDatabaseWithDisk(a.db, a.dataDir)
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(map[string]string{"commit": hash})
+}
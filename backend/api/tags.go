@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leraptor65/simple-data-flow/models"
+)
+
+// TagNode is one level of the tag hierarchy; "project/alpha" is a child of "project".
+type TagNode struct {
+	Name     string     `json:"name"`  // last path segment, e.g. "alpha"
+	Tag      string     `json:"tag"`   // full tag, e.g. "project/alpha"
+	Count    int        `json:"count"` // notes tagged exactly with this tag
+	Total    int        `json:"total"` // distinct notes tagged with this tag or any descendant
+	Children []*TagNode `json:"children,omitempty"`
+}
+
+func (a *API) HandleListTags(w http.ResponseWriter, r *http.Request) {
+	// Expand every tag into its prefixes so parent nodes get distinct note totals
+	rows, err := a.db.Query(`
+		SELECT array_to_string(t.parts[1:i], '/') AS prefix,
+			COUNT(DISTINCT t.note_id) FILTER (WHERE i = array_length(t.parts, 1)) AS direct,
+			COUNT(DISTINCT t.note_id) AS total
+		FROM (SELECT note_id, string_to_array(tag, '/') AS parts FROM note_tags) t,
+			generate_series(1, array_length(t.parts, 1)) AS i
+		GROUP BY prefix
+		ORDER BY prefix
+	`)
+	if err != nil {
+		log.Printf("HandleListTags: %v", err)
+		http.Error(w, "Failed to list tags", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	nodes := make(map[string]*TagNode)
+	var roots []*TagNode
+	for rows.Next() {
+		n := &TagNode{}
+		if err := rows.Scan(&n.Tag, &n.Count, &n.Total); err != nil {
+			continue
+		}
+		n.Name = n.Tag
+		if idx := strings.LastIndex(n.Tag, "/"); idx >= 0 {
+			n.Name = n.Tag[idx+1:]
+		}
+		nodes[n.Tag] = n
+	}
+
+	// Attach each node to its parent; prefixes always exist because of the expansion above
+	for tag, n := range nodes {
+		idx := strings.LastIndex(tag, "/")
+		if idx < 0 {
+			roots = append(roots, n)
+			continue
+		}
+		if parent, ok := nodes[tag[:idx]]; ok {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	sortTagNodes(roots)
+
+	if roots == nil {
+		roots = []*TagNode{}
+	}
+	setJSON(w)
+	json.NewEncoder(w).Encode(roots)
+}
+
+func sortTagNodes(nodes []*TagNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, n := range nodes {
+		sortTagNodes(n.Children)
+	}
+}
+
+// HandleGetTagNotes lists notes carrying a tag. Nested tags must be URL-encoded
+// (project%2Falpha). Descendant tags are included unless exact=true.
+func (a *API) HandleGetTagNotes(w http.ResponseWriter, r *http.Request) {
+	tag, _ := url.PathUnescape(chi.URLParam(r, "tag"))
+	tag = strings.ToLower(strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "#"), "/"))
+	if tag == "" {
+		http.Error(w, "Tag is required", http.StatusBadRequest)
+		return
+	}
+
+	notes, err := a.notesWithTag(tag, r.URL.Query().Get("exact") != "true")
+	if err != nil {
+		log.Printf("HandleGetTagNotes: %v", err)
+		http.Error(w, "Failed to get tagged notes", http.StatusInternalServerError)
+		return
+	}
+
+	setJSON(w)
+	json.NewEncoder(w).Encode(notes)
+}
+
+// notesWithTag returns note metadata (without content) for a tag, optionally
+// including notes tagged with any nested child of it.
+func (a *API) notesWithTag(tag string, includeChildren bool) ([]models.Note, error) {
+	pattern := ""
+	if includeChildren {
+		// Escape LIKE wildcards; tags may legitimately contain '_'
+		pattern = likeEscaper.Replace(tag) + "/%"
+	}
+
+	rows, err := a.db.Query(`
+		SELECT n.id, n.filename, n.title, COALESCE(n.frontmatter, '{}'), n.last_modified
+		FROM notes n
+		WHERE n.id IN (
+			SELECT note_id FROM note_tags
+			WHERE tag = $1 OR ($2 <> '' AND tag LIKE $2)
+		)
+		ORDER BY n.filename
+	`, tag, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []models.Note{}
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(&n.ID, &n.Filename, &n.Title, &n.Frontmatter, &n.LastModified); err == nil {
+			notes = append(notes, n)
+		}
+	}
+	return notes, rows.Err()
+}
@@ -0,0 +1,187 @@
+package gitops
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const recycleBinDir = ".recycle_bin"
+
+// ActivityFilter narrows the vault timeline. Zero values match everything.
+type ActivityFilter struct {
+	Author string // case-insensitive substring of author name or email
+	Folder string // only changes under this folder
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
+}
+
+// FileMove is a rename or move between two paths.
+type FileMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ActivityEntry lists the notes one commit created, modified, moved or deleted.
+type ActivityEntry struct {
+	Hash     string     `json:"hash"`
+	Message  string     `json:"message"`
+	Author   string     `json:"author"`
+	Email    string     `json:"email"`
+	Date     time.Time  `json:"date"`
+	Created  []string   `json:"created"`
+	Modified []string   `json:"modified"`
+	Moved    []FileMove `json:"moved"`
+	Deleted  []string   `json:"deleted"`
+}
+
+// GetActivity walks the log from HEAD, newest first, and classifies each
+// commit's changes against its first parent with rename detection. Moves into
+// the recycle bin count as deletions and moves out of it as creations. Commits
+// with no matching changes are skipped before pagination, and hasMore reports
+// whether another page exists.
+func (g *GitManager) GetActivity(f ActivityFilter) (entries []ActivityEntry, hasMore bool, err error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, false, err
+	}
+
+	opts := &git.LogOptions{Order: git.LogOrderCommitterTime}
+	if !f.Since.IsZero() {
+		opts.Since = &f.Since
+	}
+	if !f.Until.IsZero() {
+		opts.Until = &f.Until
+	}
+	iter, err := repo.Log(opts)
+	if err == plumbing.ErrReferenceNotFound {
+		return []ActivityEntry{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	folder := strings.Trim(f.Folder, "/")
+	author := strings.ToLower(f.Author)
+	entries = []ActivityEntry{}
+	skipped := 0
+	err = iter.ForEach(func(c *object.Commit) error {
+		if author != "" &&
+			!strings.Contains(strings.ToLower(c.Author.Name), author) &&
+			!strings.Contains(strings.ToLower(c.Author.Email), author) {
+			return nil
+		}
+
+		entry, err := g.commitActivity(c, folder)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		if skipped < f.Offset {
+			skipped++
+			return nil
+		}
+		if f.Limit > 0 && len(entries) == f.Limit {
+			hasMore = true
+			return storer.ErrStop
+		}
+		entries = append(entries, *entry)
+		return nil
+	})
+	return entries, hasMore, err
+}
+
+// commitActivity classifies the changes in c, or returns nil when none fall
+// under folder.
+func (g *GitManager) commitActivity(c *object.Commit, folder string) (*ActivityEntry, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if len(c.ParentHashes) > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &ActivityEntry{
+		Hash:     c.Hash.String(),
+		Message:  strings.TrimSpace(strings.SplitN(c.Message, "\n", 2)[0]),
+		Author:   c.Author.Name,
+		Email:    c.Author.Email,
+		Date:     c.Author.When,
+		Created:  []string{},
+		Modified: []string{},
+		Moved:    []FileMove{},
+		Deleted:  []string{},
+	}
+	matched := false
+	for _, ch := range changes {
+		from, to := ch.From.Name, ch.To.Name
+		if g.isAppStateFile(from) || g.isAppStateFile(to) {
+			continue
+		}
+		// Files that only ever lived in the bin, e.g. purged or migrated out
+		if (from == "" && inFolder(to, recycleBinDir)) || (to == "" && inFolder(from, recycleBinDir)) {
+			continue
+		}
+		if !inFolder(from, folder) && !inFolder(to, folder) {
+			continue
+		}
+		matched = true
+		switch {
+		case from == "":
+			e.Created = append(e.Created, to)
+		case to == "":
+			e.Deleted = append(e.Deleted, from)
+		case from == to:
+			e.Modified = append(e.Modified, to)
+		case inFolder(to, recycleBinDir) && !inFolder(from, recycleBinDir):
+			e.Deleted = append(e.Deleted, from)
+		case inFolder(from, recycleBinDir) && !inFolder(to, recycleBinDir):
+			e.Created = append(e.Created, to)
+		default:
+			e.Moved = append(e.Moved, FileMove{From: from, To: to})
+		}
+	}
+	if !matched {
+		return nil, nil
+	}
+	return e, nil
+}
+
+func inFolder(name, folder string) bool {
+	if name == "" {
+		return false
+	}
+	return folder == "" || name == folder || strings.HasPrefix(name, folder+"/")
+}
+
+// isAppStateFile reports files the app keeps, or used to keep, in the vault for
+// its own use. The recycle bin is handled separately since moves into it are
+// deletions.
+func (g *GitManager) isAppStateFile(name string) bool {
+	if name == "" || inFolder(name, recycleBinDir) {
+		return false
+	}
+	return strings.HasPrefix(path.Base(name), ".git") || g.IsStatePath(name)
+}
@@ -0,0 +1,50 @@
+package gitops
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Author identifies the person a change is made on behalf of. The configured
+// git identity (git config user.name/user.email) is always the committer.
+type Author struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type authorKey struct{}
+
+// WithAuthor returns a context carrying the author for commits made with it.
+func WithAuthor(ctx context.Context, a Author) context.Context {
+	return context.WithValue(ctx, authorKey{}, a)
+}
+
+// AuthorFromContext returns the author set by WithAuthor, if any.
+func AuthorFromContext(ctx context.Context) (Author, bool) {
+	if ctx == nil {
+		return Author{}, false
+	}
+	a, ok := ctx.Value(authorKey{}).(Author)
+	if !ok || (a.Name == "" && a.Email == "") {
+		return Author{}, false
+	}
+	return a, true
+}
+
+// signatures returns the author and committer for a commit made with ctx. Without
+// a request author both are the configured identity, as before.
+func (g *GitManager) signatures(ctx context.Context) (author, committer *object.Signature) {
+	committer = g.getAuthorSignature()
+	a, ok := AuthorFromContext(ctx)
+	if !ok {
+		return committer, committer
+	}
+	name, email := a.Name, a.Email
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	return &object.Signature{Name: name, Email: email, When: time.Now()}, committer
+}
@@ -0,0 +1,109 @@
+package gitops
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// maxBlameCacheEntries bounds the per-HEAD blame cache.
+const maxBlameCacheEntries = 256
+
+// BlameLine attributes one line of a note to the commit that last changed it.
+type BlameLine struct {
+	Line   int       `json:"line"`
+	Text   string    `json:"text"`
+	Author string    `json:"author"`
+	Email  string    `json:"email"`
+	Hash   string    `json:"hash"`
+	Date   time.Time `json:"date"`
+}
+
+// BlameResult is the blame of a file at a given HEAD commit.
+type BlameResult struct {
+	File  string      `json:"file"`
+	Head  string      `json:"head"`
+	Lines []BlameLine `json:"lines"`
+}
+
+// blameCache holds results for the current HEAD only; any new commit
+// invalidates everything, since a blame depends on the whole history.
+type blameCache struct {
+	mu      sync.Mutex
+	head    plumbing.Hash
+	results map[string]*BlameResult
+}
+
+func (c *blameCache) get(head plumbing.Hash, file string) *BlameResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head != head {
+		return nil
+	}
+	return c.results[file]
+}
+
+func (c *blameCache) put(head plumbing.Hash, file string, res *BlameResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head != head || c.results == nil || len(c.results) >= maxBlameCacheEntries {
+		c.head = head
+		c.results = make(map[string]*BlameResult)
+	}
+	c.results[file] = res
+}
+
+// BlameFile returns per-line authorship of filename as committed at HEAD.
+// Uncommitted edits in the working copy are not reflected. Results are cached
+// until HEAD moves. ErrFileNotFound is returned if HEAD does not contain the file.
+func (g *GitManager) BlameFile(filename string) (*BlameResult, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.ToSlash(filename)
+	if res := g.blame.get(head.Hash(), path); res != nil {
+		return res, nil
+	}
+
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.File(path); err != nil {
+		return nil, ErrFileNotFound
+	}
+	blame, err := git.Blame(c, path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BlameResult{
+		File:  path,
+		Head:  head.Hash().String(),
+		Lines: make([]BlameLine, 0, len(blame.Lines)),
+	}
+	for i, l := range blame.Lines {
+		res.Lines = append(res.Lines, BlameLine{
+			Line:   i + 1,
+			Text:   l.Text,
+			Author: l.AuthorName,
+			Email:  l.Author,
+			Hash:   l.Hash.String(),
+			Date:   l.Date,
+		})
+	}
+	g.blame.put(head.Hash(), path, res)
+	return res, nil
+}
@@ -0,0 +1,226 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrFileNotFound is returned when a file does not exist at the requested commit.
+var ErrFileNotFound = errors.New("file not found at commit")
+
+// DeletedFile is a path that existed in an earlier commit but not in HEAD.
+type DeletedFile struct {
+	Path      string    `json:"path"`
+	LastSeen  string    `json:"last_seen"` // newest commit that still has the file
+	DeletedIn string    `json:"deleted_in"`
+	DeletedBy string    `json:"deleted_by"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Message   string    `json:"message"`
+}
+
+// deletedCache holds the deleted file list for the current HEAD, like
+// blameCache; any new commit invalidates it.
+type deletedCache struct {
+	mu    sync.Mutex
+	head  plumbing.Hash
+	files []DeletedFile
+}
+
+func (c *deletedCache) get(head plumbing.Hash) []DeletedFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head != head {
+		return nil
+	}
+	return c.files
+}
+
+func (c *deletedCache) put(head plumbing.Hash, files []DeletedFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.head, c.files = head, files
+}
+
+// GetDeletedFiles walks history newest first and reports every file that was
+// deleted and is not present in HEAD, keeping only the most recent deletion per
+// path. Moved files are not deletions. App state and recycle bin entries are
+// skipped. Results are cached until HEAD moves and must not be modified.
+func (g *GitManager) GetDeletedFiles() ([]DeletedFile, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return []DeletedFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if files := g.deleted.get(head.Hash()); files != nil {
+		return files, nil
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash(), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	deleted := []DeletedFile{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if len(c.ParentHashes) == 0 {
+			return nil
+		}
+		parent, err := c.Parent(0)
+		if err != nil {
+			return err
+		}
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+		if err != nil {
+			return err
+		}
+		for _, ch := range changes {
+			name := ch.From.Name
+			if name == "" || seen[name] {
+				continue
+			}
+			// The latest change to a path decides, so a move hides older deletions
+			seen[name] = true
+			if ch.To.Name != "" {
+				continue
+			}
+			if g.isAppStateFile(name) || inFolder(name, recycleBinDir) {
+				continue
+			}
+			if _, err := headTree.FindEntry(name); err == nil {
+				continue // recreated since
+			}
+			deleted = append(deleted, DeletedFile{
+				Path:      name,
+				LastSeen:  parent.Hash.String(),
+				DeletedIn: c.Hash.String(),
+				DeletedBy: c.Author.Name,
+				DeletedAt: c.Author.When,
+				Message:   strings.TrimSpace(c.Message),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(deleted, func(i, j int) bool {
+		return deleted[i].DeletedAt.After(deleted[j].DeletedAt)
+	})
+	g.deleted.put(head.Hash(), deleted)
+	return deleted, nil
+}
+
+// LastSeen returns the newest commit on the first-parent chain from HEAD that
+// still has path, or ErrFileNotFound. Only the path's tree entry is looked up
+// at each commit, so this is much cheaper than listing every deleted file.
+func (g *GitManager) LastSeen(path string) (string, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return "", ErrFileNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	path = filepath.ToSlash(path)
+	c, err := repo.CommitObject(head.Hash())
+	for err == nil {
+		if !entryHash(c, path).IsZero() {
+			return c.Hash.String(), nil
+		}
+		if len(c.ParentHashes) == 0 {
+			return "", ErrFileNotFound
+		}
+		c, err = repo.CommitObject(c.ParentHashes[0])
+	}
+	return "", err
+}
+
+// treeChanges diffs the trees of two commits without rename detection.
+func treeChanges(from, to *object.Commit) (object.Changes, error) {
+	fromTree, err := from.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return object.DiffTree(fromTree, toTree)
+}
+
+// RestoreFile writes the content srcPath had at commit hash to destPath and
+// commits it. The caller validates destPath and checks it does not exist.
+func (g *GitManager) RestoreFile(ctx context.Context, hash, srcPath, destPath string) (string, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", err
+	}
+	h, err := repo.ResolveRevision(plumbing.Revision(hash))
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", hash, err)
+	}
+	c, err := repo.CommitObject(*h)
+	if err != nil {
+		return "", err
+	}
+	f, err := c.File(filepath.ToSlash(srcPath))
+	if err != nil {
+		return "", ErrFileNotFound
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(g.dataDir, destPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		return "", err
+	}
+
+	msg := "Restore " + destPath + " from " + h.String()[:7]
+	if destPath != srcPath {
+		msg = "Restore " + srcPath + " as " + destPath + " from " + h.String()[:7]
+	}
+	return g.CommitFile(ctx, destPath, msg)
+}
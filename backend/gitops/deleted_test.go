@@ -0,0 +1,50 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeletedFiles(t *testing.T) {
+	clearRemoteEnv(t)
+	t.Setenv("STATE_DIR", "")
+	g := NewGitManager(nil, t.TempDir())
+	ctx := context.Background()
+
+	writeNote(t, g, "gone.md", "gone\n")
+	writeNote(t, g, "moved.md", "a note long enough to be recognised after a move\n")
+	lastSeen, err := g.LastSeen("gone.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(g.dataDir, "gone.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.CommitFile(ctx, "gone.md", "Delete gone.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(g.dataDir, "moved.md"), filepath.Join(g.dataDir, "renamed.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.CommitPaths(ctx, []string{"moved.md", "renamed.md"}, "Rename moved.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := g.GetDeletedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "gone.md" || files[0].LastSeen != lastSeen {
+		t.Fatalf("deleted = %+v, want only gone.md last seen in %s", files, lastSeen)
+	}
+
+	if got, err := g.LastSeen("gone.md"); err != nil || got != lastSeen {
+		t.Errorf("LastSeen(gone.md) = %s, %v; want %s", got, err, lastSeen)
+	}
+	if _, err := g.LastSeen("never.md"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("LastSeen(never.md) error = %v, want ErrFileNotFound", err)
+	}
+}
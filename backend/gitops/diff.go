@@ -0,0 +1,311 @@
+package gitops
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// WorkingCopy names the on-disk version of a file as a diff endpoint.
+const WorkingCopy = "working"
+
+const (
+	defaultDiffContext = 3
+	maxDiffContext     = 100
+)
+
+// WordSpan is one run of an intra-line diff: "equal", "add" or "remove".
+type WordSpan struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// DiffLine is one line of a hunk. Changed lines that pair up with a line on the
+// other side carry a word-level breakdown in Words.
+type DiffLine struct {
+	Type    string     `json:"type"` // "context", "add" or "remove"
+	OldLine int        `json:"old_line,omitempty"`
+	NewLine int        `json:"new_line,omitempty"`
+	Text    string     `json:"text"`
+	Words   []WordSpan `json:"words,omitempty"`
+}
+
+// DiffHunk follows unified diff conventions: 1-based starts, and a zero-length
+// side starts at the line before the change.
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// FileDiff is the structured diff of one file between two revisions.
+type FileDiff struct {
+	File    string     `json:"file"`
+	From    string     `json:"from"` // resolved commit hash, or "working"
+	To      string     `json:"to"`
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Binary  bool       `json:"binary"`
+	Hunks   []DiffHunk `json:"hunks"`
+}
+
+// DiffFile diffs filename between two revisions. A revision is anything git
+// can resolve (a hash, HEAD, HEAD~2) or WorkingCopy for the file on disk. A file
+// missing at a revision diffs as empty, so additions and deletions show up as
+// a single hunk. context is the number of unchanged lines around each change.
+func (g *GitManager) DiffFile(filename, from, to string, context int) (*FileDiff, error) {
+	if context < 0 {
+		context = defaultDiffContext
+	}
+	if context > maxDiffContext {
+		context = maxDiffContext
+	}
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	fromRev, oldText, err := g.contentAt(repo, filename, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, newText, err := g.contentAt(repo, filename, to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &FileDiff{File: filename, From: fromRev, To: toRev, Hunks: []DiffHunk{}}
+	if isBinary(oldText) || isBinary(newText) {
+		d.Binary = oldText != newText
+		return d, nil
+	}
+	d.Hunks = buildHunks(splitLines(oldText), splitLines(newText), context)
+	for _, h := range d.Hunks {
+		for _, l := range h.Lines {
+			switch l.Type {
+			case "add":
+				d.Added++
+			case "remove":
+				d.Removed++
+			}
+		}
+	}
+	return d, nil
+}
+
+// contentAt returns the resolved revision name and the file's content there.
+func (g *GitManager) contentAt(repo *git.Repository, filename, rev string) (string, string, error) {
+	if rev == WorkingCopy {
+		data, err := os.ReadFile(filepath.Join(g.dataDir, filename))
+		if os.IsNotExist(err) {
+			return WorkingCopy, "", nil
+		}
+		return WorkingCopy, string(data), err
+	}
+
+	h, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", "", fmt.Errorf("resolving %s: %w", rev, err)
+	}
+	c, err := repo.CommitObject(*h)
+	if err != nil {
+		return "", "", err
+	}
+	f, err := c.File(g.pathAtCommit(c, filename))
+	if err != nil {
+		// Not present at this revision
+		return h.String(), "", nil
+	}
+	content, err := f.Contents()
+	return h.String(), content, err
+}
+
+func isBinary(text string) bool {
+	if len(text) > 8000 {
+		text = text[:8000]
+	}
+	return strings.IndexByte(text, 0) >= 0
+}
+
+// buildHunks turns a line diff into unified-style hunks with context lines.
+func buildHunks(a, b []string, context int) []DiffHunk {
+	type entry struct {
+		line    DiffLine
+		oldPos  int // lines of a consumed before this entry
+		newPos  int
+		changed bool
+	}
+
+	var flat []entry
+	oldPos, newPos := 0, 0
+	ops := diffLines(a, b)
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		switch op.kind {
+		case diffmatchpatch.DiffEqual:
+			for k := 0; k < op.count; k++ {
+				flat = append(flat, entry{
+					line:   DiffLine{Type: "context", OldLine: oldPos + 1, NewLine: newPos + 1, Text: trimEOL(a[op.aStart+k])},
+					oldPos: oldPos, newPos: newPos,
+				})
+				oldPos++
+				newPos++
+			}
+		case diffmatchpatch.DiffDelete:
+			removed := a[op.aStart : op.aStart+op.count]
+			var added []string
+			// A delete followed by an insert is a replacement; pair the lines for word diffs
+			if i+1 < len(ops) && ops[i+1].kind == diffmatchpatch.DiffInsert {
+				next := ops[i+1]
+				added = b[next.bStart : next.bStart+next.count]
+				i++
+			}
+			removeLines := make([]DiffLine, len(removed))
+			addLines := make([]DiffLine, len(added))
+			for k, l := range removed {
+				removeLines[k] = DiffLine{Type: "remove", OldLine: oldPos + k + 1, Text: trimEOL(l)}
+			}
+			for k, l := range added {
+				addLines[k] = DiffLine{Type: "add", NewLine: newPos + k + 1, Text: trimEOL(l)}
+			}
+			for k := 0; k < len(removed) && k < len(added); k++ {
+				removeLines[k].Words, addLines[k].Words = wordDiff(removeLines[k].Text, addLines[k].Text)
+			}
+			for _, l := range removeLines {
+				flat = append(flat, entry{line: l, oldPos: oldPos, newPos: newPos, changed: true})
+				oldPos++
+			}
+			for _, l := range addLines {
+				flat = append(flat, entry{line: l, oldPos: oldPos, newPos: newPos, changed: true})
+				newPos++
+			}
+		case diffmatchpatch.DiffInsert:
+			for k := 0; k < op.count; k++ {
+				flat = append(flat, entry{
+					line:   DiffLine{Type: "add", NewLine: newPos + 1, Text: trimEOL(b[op.bStart+k])},
+					oldPos: oldPos, newPos: newPos, changed: true,
+				})
+				newPos++
+			}
+		}
+	}
+
+	// Group changes whose context windows touch into one hunk
+	var hunks []DiffHunk
+	for i := 0; i < len(flat); {
+		if !flat[i].changed {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(flat); j++ {
+			if flat[j].changed {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		stop := end + context + 1
+		if stop > len(flat) {
+			stop = len(flat)
+		}
+
+		h := DiffHunk{Lines: make([]DiffLine, 0, stop-start)}
+		for _, e := range flat[start:stop] {
+			h.Lines = append(h.Lines, e.line)
+			if e.line.Type != "add" {
+				h.OldLines++
+			}
+			if e.line.Type != "remove" {
+				h.NewLines++
+			}
+		}
+		h.OldStart = flat[start].oldPos
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		h.NewStart = flat[start].newPos
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+		i = stop
+	}
+	if hunks == nil {
+		hunks = []DiffHunk{}
+	}
+	return hunks
+}
+
+func trimEOL(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
+
+// wordDiff splits two versions of a line into word, space and punctuation
+// tokens and diffs them, returning the spans for the old and the new line.
+func wordDiff(oldLine, newLine string) ([]WordSpan, []WordSpan) {
+	a, b := wordTokens(oldLine), wordTokens(newLine)
+	var oldSpans, newSpans []WordSpan
+	appendSpan := func(spans []WordSpan, typ, text string) []WordSpan {
+		if n := len(spans); n > 0 && spans[n-1].Type == typ {
+			spans[n-1].Text += text
+			return spans
+		}
+		return append(spans, WordSpan{Type: typ, Text: text})
+	}
+	for _, op := range diffLines(a, b) {
+		switch op.kind {
+		case diffmatchpatch.DiffEqual:
+			text := strings.Join(a[op.aStart:op.aStart+op.count], "")
+			oldSpans = appendSpan(oldSpans, "equal", text)
+			newSpans = appendSpan(newSpans, "equal", text)
+		case diffmatchpatch.DiffDelete:
+			oldSpans = appendSpan(oldSpans, "remove", strings.Join(a[op.aStart:op.aStart+op.count], ""))
+		case diffmatchpatch.DiffInsert:
+			newSpans = appendSpan(newSpans, "add", strings.Join(b[op.bStart:op.bStart+op.count], ""))
+		}
+	}
+	return oldSpans, newSpans
+}
+
+// wordTokens splits a line into runs of letters/digits, runs of whitespace and
+// single punctuation characters.
+func wordTokens(s string) []string {
+	var tokens []string
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+	start := 0
+	prev := -1
+	for i, r := range s {
+		c := class(r)
+		if i > start && (c != prev || c == 0) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		prev = c
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
@@ -0,0 +1,94 @@
+package gitops
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// formatHunks renders hunks as unified diff text for comparison.
+func formatHunks(hunks []DiffHunk) []string {
+	var out []string
+	for _, h := range hunks {
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			prefix := map[string]string{"context": " ", "add": "+", "remove": "-"}[l.Type]
+			out = append(out, prefix+l.Text)
+		}
+	}
+	return out
+}
+
+func TestBuildHunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    []string
+	}{
+		{
+			name: "unchanged",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: nil,
+		},
+		{
+			name:    "distant changes make separate hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:       "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			context: 1,
+			want: []string{
+				"@@ -2,3 +2,3 @@", " 2", "-3", "+three", " 4",
+				"@@ -10,1 +10,2 @@", " 10", "+11",
+			},
+		},
+		{
+			name:    "close changes share a hunk",
+			a:       "1\n2\n3\n4\n5\n",
+			b:       "one\n2\n3\n4\nfive\n",
+			context: 2,
+			want: []string{
+				"@@ -1,5 +1,5 @@", "-1", "+one", " 2", " 3", " 4", "-5", "+five",
+			},
+		},
+		{
+			name:    "an added file starts at line 0 on the old side",
+			a:       "",
+			b:       "a\n",
+			context: 3,
+			want:    []string{"@@ -0,0 +1,1 @@", "+a"},
+		},
+		{
+			name:    "a deleted file starts at line 0 on the new side",
+			a:       "a\n",
+			b:       "",
+			context: 3,
+			want:    []string{"@@ -1,1 +0,0 @@", "-a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatHunks(buildHunks(splitLines(tt.a), splitLines(tt.b), tt.context))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildHunksPairsReplacedLinesForWordDiffs(t *testing.T) {
+	hunks := buildHunks(splitLines("the quick fox\n"), splitLines("the slow fox\n"), 0)
+	if len(hunks) != 1 || len(hunks[0].Lines) != 2 {
+		t.Fatalf("got %+v", hunks)
+	}
+	removed, added := hunks[0].Lines[0].Words, hunks[0].Lines[1].Words
+	want := []WordSpan{{Type: "equal", Text: "the "}, {Type: "remove", Text: "quick"}, {Type: "equal", Text: " fox"}}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed words = %+v, want %+v", removed, want)
+	}
+	want = []WordSpan{{Type: "equal", Text: "the "}, {Type: "add", Text: "slow"}, {Type: "equal", Text: " fox"}}
+	if !reflect.DeepEqual(added, want) {
+		t.Errorf("added words = %+v, want %+v", added, want)
+	}
+}
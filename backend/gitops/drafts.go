@@ -0,0 +1,527 @@
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Drafts are local branches refs/heads/draft/<id> whose commits are written
+// straight to the object store. The worktree always stays on the main branch,
+// so draft saves never show up in CommitAll or get pushed; merging lands the
+// whole draft on main as a single commit.
+
+// draftStateFile lives inside .git so it is never committed.
+const draftStateFile = "asdf-drafts.json"
+
+const draftRefPrefix = "refs/heads/draft/"
+
+var (
+	ErrInvalidDraftID    = errors.New("invalid draft id")
+	ErrDraftNotFound     = errors.New("draft not found")
+	ErrOutsideScope      = errors.New("file is outside the draft's scope")
+	ErrDraftConflicts    = errors.New("draft conflicts with changes on main")
+	ErrNothingToMerge    = errors.New("draft has no changes")
+	errDraftStateCorrupt = errors.New("draft state is unreadable")
+)
+
+// Draft is a branch for reworking one note or folder away from main.
+type Draft struct {
+	ID      string    `json:"id"`
+	Scope   string    `json:"scope"` // note path or folder the draft may change
+	Base    string    `json:"base"`  // main commit the draft started from
+	Head    string    `json:"head"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Files   []string  `json:"files"` // files changed relative to Base
+}
+
+type draftState struct {
+	Scope   string    `json:"scope"`
+	Base    string    `json:"base"`
+	Created time.Time `json:"created"`
+}
+
+// DraftMergeConflict is a file that changed on both main and the draft in
+// overlapping places.
+type DraftMergeConflict struct {
+	Path   string `json:"path"`
+	Merged string `json:"merged"` // content with conflict markers
+}
+
+func (g *GitManager) draftStatePath() string {
+	return filepath.Join(g.dataDir, ".git", draftStateFile)
+}
+
+func (g *GitManager) loadDrafts() (map[string]draftState, error) {
+	drafts := make(map[string]draftState)
+	data, err := os.ReadFile(g.draftStatePath())
+	if os.IsNotExist(err) {
+		return drafts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &drafts); err != nil {
+		return nil, errDraftStateCorrupt
+	}
+	return drafts, nil
+}
+
+func (g *GitManager) saveDrafts(drafts map[string]draftState) error {
+	data, err := json.MarshalIndent(drafts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(g.draftStatePath(), data, 0644)
+}
+
+func inScope(file, scope string) bool {
+	return file == scope || inFolder(file, scope)
+}
+
+// CreateDraft starts a draft branch for a note or folder from the current HEAD.
+func (g *GitManager) CreateDraft(scope string) (Draft, error) {
+	scope = strings.Trim(filepath.ToSlash(filepath.Clean(scope)), "/")
+	if scope == "" || scope == "." {
+		return Draft{}, fmt.Errorf("a note or folder is required")
+	}
+
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return Draft{}, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return Draft{}, fmt.Errorf("vault has no commits to branch from: %w", err)
+	}
+	drafts, err := g.loadDrafts()
+	if err != nil {
+		return Draft{}, err
+	}
+
+	base := SnapshotID(strings.TrimSuffix(scope, ".md"))
+	if base == "" {
+		base = "draft"
+	}
+	id := base
+	for n := 2; ; n++ {
+		if _, taken := drafts[id]; !taken {
+			if _, err := repo.Reference(plumbing.ReferenceName(draftRefPrefix+id), false); err != nil {
+				break
+			}
+		}
+		id = base + "-" + strconv.Itoa(n)
+	}
+
+	ref := plumbing.NewHashReference(plumbing.ReferenceName(draftRefPrefix+id), head.Hash())
+	if err := repo.Storer.SetReference(ref); err != nil {
+		return Draft{}, err
+	}
+	st := draftState{Scope: scope, Base: head.Hash().String(), Created: time.Now()}
+	drafts[id] = st
+	if err := g.saveDrafts(drafts); err != nil {
+		return Draft{}, err
+	}
+	return g.describeDraft(repo, id, st)
+}
+
+// validDraftID reports whether id could name a draft. Ids are made by
+// SnapshotID, so they follow the same rules as snapshot ids.
+func validDraftID(id string) bool {
+	return ValidSnapshotID(id)
+}
+
+// draft loads a draft's state and current head commit.
+func (g *GitManager) draft(repo *git.Repository, id string) (draftState, *object.Commit, error) {
+	if !validDraftID(id) {
+		return draftState{}, nil, ErrInvalidDraftID
+	}
+	drafts, err := g.loadDrafts()
+	if err != nil {
+		return draftState{}, nil, err
+	}
+	st, ok := drafts[id]
+	if !ok {
+		return draftState{}, nil, ErrDraftNotFound
+	}
+	ref, err := repo.Reference(plumbing.ReferenceName(draftRefPrefix+id), true)
+	if err != nil {
+		return draftState{}, nil, ErrDraftNotFound
+	}
+	head, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return draftState{}, nil, err
+	}
+	return st, head, nil
+}
+
+func (g *GitManager) describeDraft(repo *git.Repository, id string, st draftState) (Draft, error) {
+	_, head, err := g.draft(repo, id)
+	if err != nil {
+		return Draft{}, err
+	}
+	d := Draft{
+		ID:      id,
+		Scope:   st.Scope,
+		Base:    st.Base,
+		Head:    head.Hash.String(),
+		Created: st.Created,
+		Updated: head.Committer.When,
+		Files:   []string{},
+	}
+	if head.Hash.String() == st.Base {
+		d.Updated = st.Created
+		return d, nil
+	}
+	base, err := repo.CommitObject(plumbing.NewHash(st.Base))
+	if err != nil {
+		return Draft{}, err
+	}
+	changes, err := treeChanges(base, head)
+	if err != nil {
+		return Draft{}, err
+	}
+	for _, ch := range changes {
+		if ch.To.Name != "" {
+			d.Files = append(d.Files, ch.To.Name)
+		}
+	}
+	sort.Strings(d.Files)
+	return d, nil
+}
+
+// ListDrafts returns every open draft, most recently updated first.
+func (g *GitManager) ListDrafts() ([]Draft, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := g.loadDrafts()
+	if err != nil {
+		return nil, err
+	}
+	list := []Draft{}
+	for id, st := range drafts {
+		d, err := g.describeDraft(repo, id, st)
+		if err != nil {
+			continue // branch removed outside the app
+		}
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Updated.After(list[j].Updated) })
+	return list, nil
+}
+
+// GetDraft describes one draft.
+func (g *GitManager) GetDraft(id string) (Draft, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return Draft{}, err
+	}
+	st, _, err := g.draft(repo, id)
+	if err != nil {
+		return Draft{}, err
+	}
+	return g.describeDraft(repo, id, st)
+}
+
+// DraftFileContent reads a file from a draft. Files the draft has not touched
+// read as they were when the draft was created.
+func (g *GitManager) DraftFileContent(id, filename string) (string, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", err
+	}
+	_, head, err := g.draft(repo, id)
+	if err != nil {
+		return "", err
+	}
+	f, err := head.File(filepath.ToSlash(filename))
+	if err != nil {
+		return "", ErrFileNotFound
+	}
+	return f.Contents()
+}
+
+// SaveDraftFile commits content for filename on the draft branch without
+// touching the worktree.
+func (g *GitManager) SaveDraftFile(ctx context.Context, id, filename, content, message string) (string, error) {
+	path := filepath.ToSlash(filepath.Clean(filename))
+
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", err
+	}
+	st, head, err := g.draft(repo, id)
+	if err != nil {
+		return "", err
+	}
+	if !inScope(path, st.Scope) {
+		return "", ErrOutsideScope
+	}
+
+	tree, err := head.Tree()
+	if err != nil {
+		return "", err
+	}
+	blob, err := writeBlob(repo, []byte(content))
+	if err != nil {
+		return "", err
+	}
+	treeHash, err := replaceInTree(repo, tree, strings.Split(path, "/"), blob)
+	if err != nil {
+		return "", err
+	}
+	if treeHash == tree.Hash {
+		return head.Hash.String(), nil // unchanged
+	}
+
+	author, committer := g.signatures(ctx)
+	commit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash},
+	}
+	if err := g.signing.sign(commit); err != nil {
+		return "", err
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+	ref := plumbing.NewHashReference(plumbing.ReferenceName(draftRefPrefix+id), hash)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+func writeBlob(repo *git.Repository, content []byte) (plumbing.Hash, error) {
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// replaceInTree writes a copy of tree (which may be nil) with the blob at parts
+// set, creating intermediate directories, and returns the new tree's hash.
+func replaceInTree(repo *git.Repository, tree *object.Tree, parts []string, blob plumbing.Hash) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	if tree != nil {
+		entries = append(entries, tree.Entries...)
+	}
+
+	name := parts[0]
+	idx := -1
+	for i, e := range entries {
+		if e.Name == name {
+			idx = i
+			break
+		}
+	}
+
+	var entry object.TreeEntry
+	if len(parts) == 1 {
+		entry = object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: blob}
+	} else {
+		var sub *object.Tree
+		if idx >= 0 && entries[idx].Mode == filemode.Dir {
+			t, err := repo.TreeObject(entries[idx].Hash)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+			sub = t
+		}
+		h, err := replaceInTree(repo, sub, parts[1:], blob)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entry = object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: h}
+	}
+	if idx >= 0 {
+		entries[idx] = entry
+	} else {
+		entries = append(entries, entry)
+	}
+
+	// Git orders entries by name, with directories compared as if they ended in "/"
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	obj := repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return repo.Storer.SetEncodedObject(obj)
+}
+
+// DiffDraft diffs every file the draft changed against the current main branch.
+func (g *GitManager) DiffDraft(id string, context int) ([]*FileDiff, error) {
+	d, err := g.GetDraft(id)
+	if err != nil {
+		return nil, err
+	}
+	diffs := []*FileDiff{}
+	for _, file := range d.Files {
+		fd, err := g.DiffFile(file, "HEAD", d.Head, context)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, fd)
+	}
+	return diffs, nil
+}
+
+// MergeDraft applies the draft's changes to main as one commit and deletes the
+// draft. Files also changed on main since the draft started are merged line by
+// line; if any overlap, nothing is written and the conflicts are returned with
+// ErrDraftConflicts. To resolve, save the fixed content to the draft and merge
+// again with preferDraft, which takes the draft's version of conflicting files.
+func (g *GitManager) MergeDraft(ctx context.Context, id string, preferDraft bool) (string, []DraftMergeConflict, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", nil, err
+	}
+	d, err := g.GetDraft(id)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(d.Files) == 0 {
+		return "", nil, ErrNothingToMerge
+	}
+
+	base, err := repo.CommitObject(plumbing.NewHash(d.Base))
+	if err != nil {
+		return "", nil, err
+	}
+	draftHead, err := repo.CommitObject(plumbing.NewHash(d.Head))
+	if err != nil {
+		return "", nil, err
+	}
+
+	merged := make(map[string]string)
+	var conflicts []DraftMergeConflict
+	for _, file := range d.Files {
+		baseText, _ := fileText(base, file)
+		theirs, _ := fileText(draftHead, file)
+		oursData, err := os.ReadFile(filepath.Join(g.dataDir, filepath.FromSlash(file)))
+		if err != nil && !os.IsNotExist(err) {
+			return "", nil, err
+		}
+		ours := string(oursData)
+
+		switch {
+		case ours == baseText || ours == theirs || preferDraft:
+			merged[file] = theirs
+		case !isMergeable(file, ours, theirs):
+			conflicts = append(conflicts, DraftMergeConflict{Path: file})
+		default:
+			text, conflicted := merge3(baseText, ours, theirs, "draft/"+id)
+			if conflicted {
+				conflicts = append(conflicts, DraftMergeConflict{Path: file, Merged: text})
+			} else {
+				merged[file] = text
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return "", conflicts, ErrDraftConflicts
+	}
+
+	var files []string
+	for file, content := range merged {
+		fullPath := filepath.Join(g.dataDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return "", nil, err
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return "", nil, err
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	hash, err := g.enqueue(ctx, &commitRequest{files: files, message: "Merge draft " + id + " (" + d.Scope + ")"})
+	if err != nil {
+		return "", nil, err
+	}
+	if err := g.DiscardDraft(id); err != nil {
+		return hash, nil, err
+	}
+	return hash, nil, nil
+}
+
+func fileText(c *object.Commit, path string) (string, error) {
+	f, err := c.File(path)
+	if err != nil {
+		return "", err
+	}
+	return f.Contents()
+}
+
+// DiscardDraft deletes the draft branch and its state.
+func (g *GitManager) DiscardDraft(id string) error {
+	if !validDraftID(id) {
+		return ErrInvalidDraftID
+	}
+
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return err
+	}
+	drafts, err := g.loadDrafts()
+	if err != nil {
+		return err
+	}
+	if _, ok := drafts[id]; !ok {
+		return ErrDraftNotFound
+	}
+	if err := repo.Storer.RemoveReference(plumbing.ReferenceName(draftRefPrefix + id)); err != nil {
+		return err
+	}
+	delete(drafts, id)
+	return g.saveDrafts(drafts)
+}
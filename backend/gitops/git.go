@@ -2,20 +2,24 @@ package gitops
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 type CommitInfo struct {
@@ -23,14 +27,48 @@ type CommitInfo struct {
 	Message string    `json:"message"`
 	Author  string    `json:"author"`
 	Date    time.Time `json:"date"`
+	// Path is the file's name at this commit; it differs from the requested
+	// name for commits made before a rename.
+	Path        string        `json:"path"`
+	RenamedFrom string        `json:"renamed_from,omitempty"`
+	Signature   SignatureInfo `json:"signature"`
 }
 
+// GitManager owns the vault repository. Create one per process and share it:
+// all commits go through its queue so worktree writes never overlap.
 type GitManager struct {
-	dataDir string
+	db        *sql.DB
+	dataDir   string
+	stateDir  string
+	repoMu    sync.Mutex
+	queue     chan *commitRequest
+	sync      syncState
+	committed chan struct{}
+	syncNow   chan chan error
+	blame     blameCache
+	history   historyCache
+	deleted   deletedCache
+	signing   signingConfig
 }
 
-func NewGitManager(dataDir string) *GitManager {
-	return &GitManager{dataDir: dataDir}
+// NewGitManager manages the repository in dataDir. db stores the sync log and
+// may be nil, in which case sync events are only logged.
+func NewGitManager(db *sql.DB, dataDir string) *GitManager {
+	g := &GitManager{
+		db:        db,
+		dataDir:   dataDir,
+		stateDir:  resolveStateDir(dataDir),
+		queue:     make(chan *commitRequest, 64),
+		committed: make(chan struct{}, 1),
+		syncNow:   make(chan chan error),
+		signing:   loadSigningConfig(),
+	}
+	g.prepareState()
+	if db != nil {
+		g.migrateSyncLogFile()
+	}
+	go g.runQueue()
+	return g
 }
 
 func (g *GitManager) InitRepo() *git.Repository {
@@ -50,70 +88,38 @@ func (g *GitManager) InitRepo() *git.Repository {
 	return repo
 }
 
-func (g *GitManager) CommitFile(filename string, message string) {
+// openRepo is InitRepo with an error for callers that need to report failure.
+func (g *GitManager) openRepo() (*git.Repository, error) {
 	repo := g.InitRepo()
 	if repo == nil {
-		return
-	}
-
-	w, err := repo.Worktree()
-	if err != nil {
-		log.Printf("Error getting worktree: %v", err)
-		return
-	}
-
-	// Try to pull remote changes first if GitHub sync is configured
-	g.pullFromRemote(repo)
-
-	_, err = w.Add(filename)
-	if err != nil {
-		log.Printf("Error adding file to index: %v", err)
-		return
-	}
-
-	_, err = w.Commit(message, &git.CommitOptions{
-		Author: g.getAuthorSignature(),
-	})
-
-	if err != nil {
-		log.Printf("Error committing: %v", err)
-	} else {
-		// Try to push to remote if GitHub sync is configured
-		g.pushToRemote(repo)
+		return nil, fmt.Errorf("failed to init repo")
 	}
+	return repo, nil
 }
 
-func (g *GitManager) CommitAll(message string) {
-	repo := g.InitRepo()
-	if repo == nil {
-		return
-	}
-
-	w, err := repo.Worktree()
-	if err != nil {
-		log.Printf("Error getting worktree: %v", err)
-		return
-	}
-
-	// Try to pull remote changes first if GitHub sync is configured
-	g.pullFromRemote(repo)
+// CommitFile stages a single path and commits it, returning the commit hash.
+// Calls made within a short window are coalesced into one commit.
+// The commit is authored by the author on ctx (see WithAuthor), if any.
+func (g *GitManager) CommitFile(ctx context.Context, filename string, message string) (string, error) {
+	return g.CommitPaths(ctx, []string{filename}, message)
+}
 
-	err = w.AddWithOptions(&git.AddOptions{All: true})
-	if err != nil {
-		log.Printf("Error adding all to index: %v", err)
-		return
+// CommitPaths stages the given files or folders, including their deletion, and
+// commits them. Handlers that touch known paths use this rather than CommitAll
+// so concurrent changes by other users are never swept into their commit.
+func (g *GitManager) CommitPaths(ctx context.Context, paths []string, message string) (string, error) {
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		files = append(files, filepath.Clean(p))
 	}
+	return g.enqueue(ctx, &commitRequest{files: files, message: message})
+}
 
-	_, err = w.Commit(message, &git.CommitOptions{
-		Author: g.getAuthorSignature(),
-	})
-
-	if err != nil {
-		log.Printf("Error committing all: %v", err)
-	} else {
-		// Try to push to remote if GitHub sync is configured
-		g.pushToRemote(repo)
-	}
+// CommitAll stages every change in the vault and commits it, returning the commit hash.
+// Calls made within a short window are coalesced into one commit.
+// The commit is authored by the author on ctx (see WithAuthor), if any.
+func (g *GitManager) CommitAll(ctx context.Context, message string) (string, error) {
+	return g.enqueue(ctx, &commitRequest{all: true, message: message})
 }
 
 // getGHToken retrieves the GitHub auth token by running `gh auth token`.
@@ -168,156 +174,163 @@ func (g *GitManager) getAuthorSignature() *object.Signature {
 	}
 }
 
-func (g *GitManager) pullFromRemote(repo *git.Repository) {
+// pullFromRemote fetches and fast-forwards from the primary remote. It returns nil
+// when sync is disabled, no remote is configured or the repository is already up
+// to date.
+func (g *GitManager) pullFromRemote(repo *git.Repository) error {
 	if g.isSyncDisabled() {
 		log.Println("GitHub Sync: sync is disabled in settings. Skipping pull.")
-		return
+		return nil
 	}
-	repoURL := os.Getenv("GITHUB_REPO")
-	if repoURL == "" {
-		return // Remote not configured, skip pull
+	start := time.Now()
+	remotes, err := LoadRemotes()
+	if err != nil {
+		log.Printf("GitHub Sync: invalid remote configuration: %v", err)
+		g.logSync(SyncOpPull, "", start, 0, err)
+		return err
+	}
+	if len(remotes) == 0 {
+		return nil // Remote not configured, skip pull
+	}
+	primary := remotes[0]
+	before := headHash(repo)
+	record := func(op string, err error) {
+		commits := 0
+		if err == nil {
+			commits = commitsBetween(repo, before, headHash(repo))
+		}
+		g.logSync(op, primary.Name, start, commits, err)
 	}
 
-	token := getGHToken()
-
-	w, err := repo.Worktree()
+	w, err := g.worktree(repo)
 	if err != nil {
 		log.Printf("GitHub Sync: failed to get worktree for pull: %v", err)
-		g.WriteSyncLog("Pull", err)
-		return
+		record(SyncOpPull, err)
+		return err
 	}
 
-	// Prepare remote tracking and fetch origin if not defined
-	remoteName := "origin"
-	remotes, err := repo.Remotes()
-	if err == nil {
-		originExists := false
-		for _, r := range remotes {
-			if r.Config().Name == remoteName {
-				originExists = true
-				break
-			}
-		}
-		if !originExists {
-			_, err = repo.CreateRemote(&config.RemoteConfig{
-				Name: remoteName,
-				URLs: []string{repoURL},
-			})
-			if err != nil {
-				log.Printf("GitHub Sync: failed to create remote on pull: %v", err)
-				g.WriteSyncLog("Pull", err)
-				return
-			}
-		}
+	if err := ensureRemote(repo, primary); err != nil {
+		log.Printf("GitHub Sync: failed to configure remote %s on pull: %v", primary.Name, err)
+		record(SyncOpPull, err)
+		return err
 	}
 
-	var auth *http.BasicAuth
-	if token != "" {
-		auth = &http.BasicAuth{
-			Username: "git",
-			Password: token,
-		}
+	auth, err := primary.AuthMethod()
+	if err != nil {
+		log.Printf("GitHub Sync: failed to prepare credentials for %s: %v", primary.Name, err)
+		record(SyncOpPull, err)
+		return err
 	}
 
-	log.Println("GitHub Sync: pulling latest updates from remote...")
+	log.Printf("GitHub Sync: pulling latest updates from %s...", primary.Name)
 	err = w.Pull(&git.PullOptions{
-		RemoteName: remoteName,
+		RemoteName: primary.Name,
 		Auth:       auth,
 	})
 	if err != nil {
-		if err == git.NoErrAlreadyUpToDate {
+		if err == git.NoErrAlreadyUpToDate || err == transport.ErrEmptyRemoteRepository {
 			log.Println("GitHub Sync: local repository is up to date.")
-			g.WriteSyncLog("Pull", nil)
+			record(SyncOpPull, nil)
 		} else if err == git.ErrNonFastForwardUpdate {
-			log.Println("GitHub Sync: warning: remote changes could not be fast-forwarded. Manual merge required.")
-			g.WriteSyncLog("Pull", err)
+			log.Println("GitHub Sync: remote changes could not be fast-forwarded. Attempting a three-way merge...")
+			mergeErr := g.mergeRemote(repo, primary.Name)
+			if !errors.Is(mergeErr, ErrMergeDeferred) {
+				record(SyncOpMerge, mergeErr)
+			}
+			return mergeErr
 		} else {
 			log.Printf("GitHub Sync: warning: pull failed: %v. Local repository remains operational.", err)
-			g.WriteSyncLog("Pull", err)
+			record(SyncOpPull, err)
+			return err
 		}
 	} else {
 		log.Println("GitHub Sync: successfully pulled remote changes!")
-		g.WriteSyncLog("Pull", nil)
+		record(SyncOpPull, nil)
 	}
+	return nil
 }
 
-func (g *GitManager) pushToRemote(repo *git.Repository) {
+// pushToRemote pushes the current branch to every push-enabled remote, with the
+// same nil conventions as pullFromRemote. A failing remote does not stop the
+// others; their errors are joined.
+func (g *GitManager) pushToRemote(repo *git.Repository) error {
 	if g.isSyncDisabled() {
 		log.Println("GitHub Sync: sync is disabled in settings. Skipping push.")
-		return
-	}
-	repoURL := os.Getenv("GITHUB_REPO")
-	if repoURL == "" {
-		return // Remote not configured, skip push
+		return nil
 	}
-
-	token := getGHToken()
-
-	remoteName := "origin"
-	remotes, err := repo.Remotes()
+	remotes, err := LoadRemotes()
 	if err != nil {
-		log.Printf("GitHub Sync: failed to list remotes on push: %v", err)
-		g.WriteSyncLog("Push", err)
-		return
+		log.Printf("GitHub Sync: invalid remote configuration: %v", err)
+		g.logSync(SyncOpPush, "", time.Now(), 0, err)
+		return err
 	}
 
-	var originRemote *git.Remote
+	var errs []error
 	for _, r := range remotes {
-		if r.Config().Name == remoteName {
-			originRemote = r
-			break
+		if !r.PushEnabled() {
+			continue
+		}
+		if err := g.pushTo(repo, r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, err))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	if originRemote == nil {
-		_, err = repo.CreateRemote(&config.RemoteConfig{
-			Name: remoteName,
-			URLs: []string{repoURL},
-		})
-		if err != nil {
-			log.Printf("GitHub Sync: failed to create remote on push: %v", err)
-			g.WriteSyncLog("Push", err)
-			return
-		}
+func (g *GitManager) pushTo(repo *git.Repository, r Remote) error {
+	start := time.Now()
+
+	if err := ensureRemote(repo, r); err != nil {
+		log.Printf("GitHub Sync: failed to configure remote %s on push: %v", r.Name, err)
+		g.logSync(SyncOpPush, r.Name, start, 0, err)
+		return err
 	}
 
-	var auth *http.BasicAuth
-	if token != "" {
-		auth = &http.BasicAuth{
-			Username: "git",
-			Password: token,
-		}
+	auth, err := r.AuthMethod()
+	if err != nil {
+		log.Printf("GitHub Sync: failed to prepare credentials for %s: %v", r.Name, err)
+		g.logSync(SyncOpPush, r.Name, start, 0, err)
+		return err
 	}
 
-	log.Printf("GitHub Sync: pushing commits to remote repository %s...", repoURL)
+	log.Printf("GitHub Sync: pushing commits to %s (%s)...", r.Name, r.DisplayURL())
 	headRef, err := repo.Head()
 	pushOpts := &git.PushOptions{
-		RemoteName: remoteName,
+		RemoteName: r.Name,
 		Auth:       auth,
 	}
+	commits := 0
 	if err == nil {
 		refSpec := config.RefSpec(fmt.Sprintf("%s:%s", headRef.Name(), headRef.Name()))
-		pushOpts.RefSpecs = []config.RefSpec{refSpec}
+		pushOpts.RefSpecs = []config.RefSpec{refSpec, snapshotRefSpec}
+
+		// What the remote lacks, going by the last fetch or push
+		remoteHash := plumbing.ZeroHash
+		if ref, err := repo.Reference(plumbing.NewRemoteReferenceName(r.Name, headRef.Name().Short()), true); err == nil {
+			remoteHash = ref.Hash()
+		}
+		commits = commitsBetween(repo, remoteHash, headRef.Hash())
 	}
 
 	err = repo.Push(pushOpts)
 	if err != nil {
 		if err == git.NoErrAlreadyUpToDate {
 			log.Println("GitHub Sync: already up-to-date.")
-			g.WriteSyncLog("Push", nil)
+			g.logSync(SyncOpPush, r.Name, start, 0, nil)
 		} else {
-			log.Printf("GitHub Sync: warning: push failed: %v. Local repository remains operational.", err)
-			g.WriteSyncLog("Push", err)
+			log.Printf("GitHub Sync: warning: push to %s failed: %v. Local repository remains operational.", r.Name, err)
+			g.logSync(SyncOpPush, r.Name, start, 0, err)
+			return err
 		}
 	} else {
-		log.Println("GitHub Sync: successfully pushed to GitHub!")
-		g.WriteSyncLog("Push", nil)
+		log.Printf("GitHub Sync: successfully pushed to %s!", r.Name)
+		g.logSync(SyncOpPush, r.Name, start, commits, nil)
 	}
+	return nil
 }
 
 func (g *GitManager) isSyncDisabled() bool {
-	configPath := filepath.Join(g.dataDir, ".git_config.json")
-	if data, err := os.ReadFile(configPath); err == nil {
+	if data, err := os.ReadFile(g.SyncConfigPath()); err == nil {
 		var cfg struct {
 			Disabled bool `json:"disabled"`
 		}
@@ -328,104 +341,190 @@ func (g *GitManager) isSyncDisabled() bool {
 	return false
 }
 
-type SyncLogEntry struct {
-	Timestamp      string `json:"timestamp"`
-	Action         string `json:"action"`
-	Success        bool   `json:"success"`
-	Error          string `json:"error"`
-	Recommendation string `json:"recommendation"`
-}
+// maxHistoryCacheEntries bounds how many notes' histories are kept per HEAD.
+const maxHistoryCacheEntries = 64
 
-func (g *GitManager) WriteSyncLog(action string, syncErr error) {
-	logPath := filepath.Join(g.dataDir, ".git_sync_log.json")
-	var entries []SyncLogEntry
-
-	// Read existing logs if file exists
-	if data, err := os.ReadFile(logPath); err == nil {
-		json.Unmarshal(data, &entries)
-	}
-
-	// Determine recommendation
-	rec := ""
-	errStr := ""
-	if syncErr != nil {
-		errStr = syncErr.Error()
-		lowerErr := strings.ToLower(errStr)
-		if strings.Contains(lowerErr, "auth") || strings.Contains(lowerErr, "authentication") || strings.Contains(lowerErr, "401") || strings.Contains(lowerErr, "credentials") {
-			rec = "Authentication failed. Run 'gh auth login --insecure-storage' on the host machine to authenticate."
-		} else if strings.Contains(lowerErr, "username") || strings.Contains(lowerErr, "terminal") || strings.Contains(lowerErr, "prompt") {
-			rec = "Interactive prompt requested. Make sure you have logged in via 'gh auth login --insecure-storage' on the host."
-		} else if strings.Contains(lowerErr, "resolve") || strings.Contains(lowerErr, "dial tcp") || strings.Contains(lowerErr, "timeout") {
-			rec = "Network connection failed. Verify the server has internet access and your proxy/DNS settings are correct."
-		} else if strings.Contains(lowerErr, "not found") || strings.Contains(lowerErr, "404") {
-			rec = "Repository not found. Double check your GITHUB_REPO URL and ensure the repository exists on GitHub."
-		} else if strings.Contains(lowerErr, "non-fast-forward") || strings.Contains(lowerErr, "merge") {
-			rec = "Conflicts detected. Run a manual git pull/merge on the host to resolve conflicts."
-		} else {
-			rec = "Check GITHUB_REPO settings and run 'gh auth status' on the host for diagnostics."
-		}
-	}
+// fileHistory is a note's history along the first-parent chain from HEAD: the
+// commits that changed it, newest first, and the path it had at each commit of
+// the chain.
+type fileHistory struct {
+	commits []CommitInfo
+	paths   map[plumbing.Hash]string
+}
 
-	newEntry := SyncLogEntry{
-		Timestamp:      time.Now().Format(time.RFC3339),
-		Action:         action,
-		Success:        syncErr == nil,
-		Error:          errStr,
-		Recommendation: rec,
-	}
+// historyCache holds histories for the current HEAD only, like blameCache.
+type historyCache struct {
+	mu      sync.Mutex
+	head    plumbing.Hash
+	results map[string]*fileHistory
+}
 
-	// Prepend new entry and cap at 20 entries
-	entries = append([]SyncLogEntry{newEntry}, entries...)
-	if len(entries) > 20 {
-		entries = entries[:20]
+func (c *historyCache) get(head plumbing.Hash, file string) *fileHistory {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head != head {
+		return nil
 	}
+	return c.results[file]
+}
 
-	if data, err := json.MarshalIndent(entries, "", "  "); err == nil {
-		os.WriteFile(logPath, data, 0644)
+func (c *historyCache) put(head plumbing.Hash, file string, h *fileHistory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.head != head || c.results == nil || len(c.results) >= maxHistoryCacheEntries {
+		c.head = head
+		c.results = make(map[string]*fileHistory)
 	}
+	c.results[file] = h
 }
 
-// GetSyncLogs retrieves the sync log entries from .git_sync_log.json
-func (g *GitManager) GetSyncLogs() []SyncLogEntry {
-	logPath := filepath.Join(g.dataDir, ".git_sync_log.json")
-	var entries []SyncLogEntry
-	if data, err := os.ReadFile(logPath); err == nil {
-		json.Unmarshal(data, &entries)
-	}
-	if entries == nil {
-		entries = []SyncLogEntry{}
+// GetFileHistory lists the commits that changed filename, newest first. Renames
+// are followed: when a commit added the file under its current name and removed
+// a similar file elsewhere, older commits are matched against that earlier path.
+// Each entry records the path the note had at that commit.
+func (g *GitManager) GetFileHistory(filename string) ([]CommitInfo, error) {
+	h, err := g.fileHistory(filename)
+	if err != nil || h == nil {
+		return nil, err
 	}
-	return entries
+	return h.commits, nil
 }
 
-
-func (g *GitManager) GetFileHistory(filename string) ([]CommitInfo, error) {
+// fileHistory walks the first-parent chain from HEAD, so a merge counts as one
+// change bringing in whatever the merged side did and the path is tracked along
+// a single line of history. Results are cached until HEAD moves; nil means the
+// repository has no commits yet.
+func (g *GitManager) fileHistory(filename string) (*fileHistory, error) {
 	repo := g.InitRepo()
 	if repo == nil {
 		return nil, fmt.Errorf("failed to init repo")
 	}
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil // No commits yet
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	cIter, err := repo.Log(&git.LogOptions{
-		FileName: &filename,
-	})
+	path := filepath.ToSlash(filename)
+	if h := g.history.get(head.Hash(), path); h != nil {
+		return h, nil
+	}
+	key := path
+
+	h := &fileHistory{paths: make(map[plumbing.Hash]string)}
+	c, err := repo.CommitObject(head.Hash())
 	if err != nil {
 		return nil, err
 	}
+	current := entryHash(c, path)
+	for c != nil {
+		h.paths[c.Hash] = path
 
-	var commits []CommitInfo
-	err = cIter.ForEach(func(c *object.Commit) error {
-		commits = append(commits, CommitInfo{
-			Hash:    c.Hash.String(),
-			Message: c.Message,
-			Author:  c.Author.Name,
-			Date:    c.Author.When,
-		})
-		return nil
-	})
-	return commits, err
+		var parent *object.Commit
+		before := plumbing.ZeroHash
+		if len(c.ParentHashes) > 0 {
+			if parent, err = repo.CommitObject(c.ParentHashes[0]); err != nil {
+				return nil, err
+			}
+			before = entryHash(parent, path)
+		}
+
+		if current != before {
+			info := CommitInfo{
+				Hash:    c.Hash.String(),
+				Message: c.Message,
+				Author:  c.Author.Name,
+				Date:    c.Author.When,
+				Path:    path,
+			}
+			info.Signature = g.signing.verify(c)
+
+			// The file appeared here: check whether it was moved from elsewhere
+			if !current.IsZero() && before.IsZero() && parent != nil {
+				from, err := renamedFrom(parent, c, path)
+				if err != nil {
+					return nil, err
+				}
+				if from != "" {
+					info.RenamedFrom = from
+					path = from
+					before = entryHash(parent, path)
+				}
+			}
+			h.commits = append(h.commits, info)
+		}
+
+		c, current = parent, before
+	}
+
+	g.history.put(head.Hash(), key, h)
+	return h, nil
+}
+
+// entryHash returns the blob hash of path in c's tree, or the zero hash when the
+// path does not exist there.
+func entryHash(c *object.Commit, path string) plumbing.Hash {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return entry.Hash
+}
+
+// renamedFrom reports the earlier name of path if the change from parent to c
+// was a rename, or "" if the file was newly added.
+func renamedFrom(parent, c *object.Commit, path string) (string, error) {
+	parentTree, err := parent.Tree()
+	if err != nil {
+		return "", err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+	changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return "", err
+	}
+	for _, ch := range changes {
+		if ch.To.Name == path && ch.From.Name != "" && ch.From.Name != path {
+			return ch.From.Name, nil
+		}
+	}
+	return "", nil
 }
 
-func (g *GitManager) CheckoutFile(hash string, filename string) error {
+// pathAtCommit returns the name filename had at commit c, following renames
+// through its history. Callers pass the note's current name, so older commits
+// may store it elsewhere.
+func (g *GitManager) pathAtCommit(c *object.Commit, filename string) string {
+	path := filepath.ToSlash(filename)
+	if !entryHash(c, path).IsZero() {
+		return path
+	}
+	h, err := g.fileHistory(filename)
+	if err != nil || h == nil {
+		return path
+	}
+	if p, ok := h.paths[c.Hash]; ok {
+		return p
+	}
+	// c is on a merged side branch; use the path of the newest change older than c
+	for _, info := range h.commits {
+		if !info.Date.After(c.Author.When) && !entryHash(c, info.Path).IsZero() {
+			return info.Path
+		}
+	}
+	return path
+}
+
+func (g *GitManager) CheckoutFile(ctx context.Context, hash string, filename string) error {
 	repo := g.InitRepo()
 	if repo == nil {
 		return fmt.Errorf("failed to init repo")
@@ -437,7 +536,7 @@ func (g *GitManager) CheckoutFile(hash string, filename string) error {
 		return err
 	}
 
-	f, err := c.File(filename)
+	f, err := c.File(g.pathAtCommit(c, filename))
 	if err != nil {
 		return err
 	}
@@ -453,8 +552,8 @@ func (g *GitManager) CheckoutFile(hash string, filename string) error {
 		return err
 	}
 
-	g.CommitFile(filename, "Revert "+filename+" to "+hash[:7])
-	return nil
+	_, err = g.CommitFile(ctx, filename, "Revert "+filename+" to "+hash[:7])
+	return err
 }
 
 func (g *GitManager) GetFileContentAtHash(hash string, filename string) (string, error) {
@@ -469,7 +568,7 @@ func (g *GitManager) GetFileContentAtHash(hash string, filename string) (string,
 		return "", err
 	}
 
-	f, err := c.File(filename)
+	f, err := c.File(g.pathAtCommit(c, filename))
 	if err != nil {
 		// If file doesn't exist in that commit, return empty
 		return "", nil
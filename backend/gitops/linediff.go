@@ -0,0 +1,100 @@
+package gitops
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// splitLines splits text into lines that keep their trailing newline, so joining
+// them reproduces the input exactly.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOp is one run of a line diff between a and b.
+type lineOp struct {
+	kind   diffmatchpatch.Operation
+	aStart int // index into a (for equal and delete runs)
+	bStart int // index into b (for equal and insert runs)
+	count  int
+}
+
+// diffLines computes a line-level diff of a against b. Lines are mapped to runes
+// so the character diff engine compares whole lines.
+func diffLines(a, b []string) []lineOp {
+	ids := make(map[string]rune)
+	toRunes := func(lines []string) []rune {
+		out := make([]rune, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = lineRune(len(ids))
+				ids[l] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	ra, rb := toRunes(a), toRunes(b)
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 0
+	diffs := dmp.DiffMainRunes(ra, rb, false)
+
+	var ops []lineOp
+	ai, bi := 0, 0
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		if n == 0 {
+			continue
+		}
+		op := lineOp{kind: d.Type, aStart: ai, bStart: bi, count: n}
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			ai += n
+			bi += n
+		case diffmatchpatch.DiffDelete:
+			ai += n
+		case diffmatchpatch.DiffInsert:
+			bi += n
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// lineRune maps a line id to a rune, skipping the surrogate range which is not
+// valid in Go strings.
+func lineRune(id int) rune {
+	r := rune(id + 1)
+	if r >= 0xD800 {
+		r += 0x800
+	}
+	return r
+}
+
+// lineMatches returns, for each line of a, the index of the matching line in b
+// or -1 when the line was deleted.
+func lineMatches(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	for _, op := range diffLines(a, b) {
+		if op.kind != diffmatchpatch.DiffEqual {
+			continue
+		}
+		for k := 0; k < op.count; k++ {
+			match[op.aStart+k] = op.bStart + k
+		}
+	}
+	return match
+}
@@ -0,0 +1,560 @@
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrMergeConflicts is returned by a pull that diverged and could not be merged
+// automatically. The conflicts are listed by GetConflicts until resolved.
+var ErrMergeConflicts = errors.New("merge conflicts require resolution")
+
+// ErrMergeDeferred is returned when a merge would overwrite files with edits
+// that are not committed yet. The merge is retried by the next sync, which
+// runs once the commit queue has recorded those edits.
+var ErrMergeDeferred = errors.New("merge deferred until local edits are committed")
+
+// Errors from ResolveConflict for requests that cannot apply to the merge.
+var (
+	ErrNoMergeInProgress = errors.New("no merge in progress")
+	ErrNotInConflict     = errors.New("path is not in conflict")
+	ErrUnknownResolution = errors.New("unknown resolution")
+)
+
+// mergeStateFile lives inside .git so it is never committed.
+const mergeStateFile = "asdf-merge.json"
+
+// MergeConflict describes one file that changed on both sides of a diverged sync.
+type MergeConflict struct {
+	Path         string `json:"path"`
+	Kind         string `json:"kind"` // "content", "modify/delete", "delete/modify", "add/add", "unmergeable"
+	Base         string `json:"base"`
+	Ours         string `json:"ours"`
+	Theirs       string `json:"theirs"`
+	BaseExists   bool   `json:"base_exists"`
+	OursExists   bool   `json:"ours_exists"`
+	TheirsExists bool   `json:"theirs_exists"`
+	Merged       string `json:"merged"` // content with conflict markers, for manual editing
+	Resolved     bool   `json:"resolved"`
+	Resolution   string `json:"resolution,omitempty"`
+}
+
+// ConflictResolution is the outcome of resolving one conflict.
+type ConflictResolution struct {
+	Remaining int    `json:"remaining"`
+	Commit    string `json:"commit,omitempty"` // merge commit hash once every conflict is resolved
+}
+
+type mergeResolution struct {
+	Choice  string `json:"choice"` // "ours", "theirs" or "manual"
+	Content string `json:"content,omitempty"`
+}
+
+// mergeState persists an in-progress merge between syncs and restarts.
+type mergeState struct {
+	Remote      string                     `json:"remote"`
+	Theirs      string                     `json:"theirs"`
+	DetectedAt  time.Time                  `json:"detected_at"`
+	Conflicts   []string                   `json:"conflicts"`
+	Resolutions map[string]mergeResolution `json:"resolutions"`
+}
+
+type fileChange struct {
+	path    string
+	content []byte
+	delete  bool
+}
+
+func (g *GitManager) mergeStatePath() string {
+	return filepath.Join(g.dataDir, ".git", mergeStateFile)
+}
+
+func (g *GitManager) loadMergeState() *mergeState {
+	data, err := os.ReadFile(g.mergeStatePath())
+	if err != nil {
+		return nil
+	}
+	var st mergeState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil
+	}
+	if st.Resolutions == nil {
+		st.Resolutions = make(map[string]mergeResolution)
+	}
+	return &st
+}
+
+func (g *GitManager) saveMergeState(st *mergeState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(g.mergeStatePath(), data, 0644)
+}
+
+func (g *GitManager) clearMergeState() {
+	os.Remove(g.mergeStatePath())
+}
+
+// mergeRemote merges the fetched remote branch into HEAD after a pull could not
+// fast-forward. Non-overlapping edits are merged automatically; anything else is
+// recorded as a conflict. Callers must hold repoMu.
+func (g *GitManager) mergeRemote(repo *git.Repository, remoteName string) error {
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, head.Name().Short())
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return err
+	}
+	ours, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	theirs, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+
+	st := g.loadMergeState()
+	if st == nil || st.Theirs != theirs.Hash.String() {
+		// The remote moved since conflicts were recorded; earlier resolutions no longer apply
+		st = &mergeState{
+			Remote:      remoteRef.Short(),
+			Theirs:      theirs.Hash.String(),
+			DetectedAt:  time.Now(),
+			Resolutions: make(map[string]mergeResolution),
+		}
+	}
+
+	changes, conflicts, err := threeWayMerge(ours, theirs, st.Resolutions)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		st.Conflicts = st.Conflicts[:0]
+		for _, c := range conflicts {
+			st.Conflicts = append(st.Conflicts, c.Path)
+		}
+		if err := g.saveMergeState(st); err != nil {
+			log.Printf("GitHub Sync: failed to save merge state: %v", err)
+		}
+		log.Printf("GitHub Sync: %d file(s) conflict with %s; resolve them via /api/git/conflicts", len(conflicts), st.Remote)
+		return ErrMergeConflicts
+	}
+
+	hash, err := g.applyMerge(context.Background(), repo, ours, theirs, st.Remote, changes)
+	if err != nil {
+		return err
+	}
+	g.clearMergeState()
+	log.Printf("GitHub Sync: merged %s into local branch (%s)", st.Remote, hash[:7])
+	return nil
+}
+
+// GetConflicts lists the files blocking an in-progress merge, with the base,
+// local and remote versions of each.
+func (g *GitManager) GetConflicts() ([]MergeConflict, error) {
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	st := g.loadMergeState()
+	if st == nil {
+		return []MergeConflict{}, nil
+	}
+	_, ours, theirs, err := g.mergeCommits(st)
+	if err != nil {
+		return nil, err
+	}
+
+	_, conflicts, err := threeWayMerge(ours, theirs, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) == 0 {
+		// Merged some other way since the conflicts were recorded
+		g.clearMergeState()
+		return []MergeConflict{}, nil
+	}
+	for i := range conflicts {
+		if res, ok := st.Resolutions[conflicts[i].Path]; ok {
+			conflicts[i].Resolved = true
+			conflicts[i].Resolution = res.Choice
+		}
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict records a resolution for one path. Once nothing is left
+// unresolved the merge commit is created and queued for push.
+func (g *GitManager) ResolveConflict(ctx context.Context, path, choice, content string) (ConflictResolution, error) {
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	var result ConflictResolution
+	switch choice {
+	case "ours", "theirs", "manual":
+	default:
+		return result, fmt.Errorf("%w %q", ErrUnknownResolution, choice)
+	}
+
+	st := g.loadMergeState()
+	if st == nil {
+		return result, ErrNoMergeInProgress
+	}
+	repo, ours, theirs, err := g.mergeCommits(st)
+	if err != nil {
+		return result, err
+	}
+
+	_, conflicts, err := threeWayMerge(ours, theirs, nil)
+	if err != nil {
+		return result, err
+	}
+	found := false
+	for _, c := range conflicts {
+		if c.Path == path {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return result, fmt.Errorf("%s: %w", path, ErrNotInConflict)
+	}
+
+	res := mergeResolution{Choice: choice}
+	if choice == "manual" {
+		res.Content = content
+	}
+	st.Resolutions[path] = res
+
+	changes, remaining, err := threeWayMerge(ours, theirs, st.Resolutions)
+	if err != nil {
+		return result, err
+	}
+	result.Remaining = len(remaining)
+	// Saved before applying, so a deferred merge keeps this resolution
+	if err := g.saveMergeState(st); err != nil || len(remaining) > 0 {
+		return result, err
+	}
+
+	hash, err := g.applyMerge(ctx, repo, ours, theirs, st.Remote, changes)
+	if err != nil {
+		return result, err
+	}
+	g.clearMergeState()
+	result.Commit = hash
+	g.notifyCommitted()
+	return result, nil
+}
+
+// mergeCommits loads the current HEAD and the remote commit recorded in st.
+func (g *GitManager) mergeCommits(st *mergeState) (*git.Repository, *object.Commit, *object.Commit, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ours, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	theirs, err := repo.CommitObject(plumbing.NewHash(st.Theirs))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return repo, ours, theirs, nil
+}
+
+// applyMerge writes merged files into the worktree and records a merge commit
+// with both sides as parents. It returns ErrMergeDeferred without writing
+// anything if a file it would replace has uncommitted edits, such as a save
+// still waiting in the commit queue.
+func (g *GitManager) applyMerge(ctx context.Context, repo *git.Repository, ours, theirs *object.Commit, remote string, changes []fileChange) (string, error) {
+	w, err := g.worktree(repo)
+	if err != nil {
+		return "", err
+	}
+	st, err := w.Status()
+	if err != nil {
+		return "", err
+	}
+	var dirty []string
+	for _, ch := range changes {
+		if fs, ok := st[ch.path]; ok && (fs.Worktree != git.Unmodified || fs.Staging != git.Unmodified) {
+			dirty = append(dirty, ch.path)
+		}
+	}
+	if len(dirty) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMergeDeferred, strings.Join(dirty, ", "))
+	}
+
+	for _, ch := range changes {
+		full := filepath.Join(g.dataDir, ch.path)
+		if ch.delete {
+			if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+				return "", err
+			}
+			if _, err := w.Remove(ch.path); err != nil {
+				log.Printf("GitHub Sync: failed to unstage %s: %v", ch.path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(full, ch.content, 0644); err != nil {
+			return "", err
+		}
+		if _, err := w.Add(ch.path); err != nil {
+			return "", err
+		}
+	}
+
+	author, committer := g.signatures(ctx)
+	opts := &git.CommitOptions{
+		Author:            author,
+		Committer:         committer,
+		Parents:           []plumbing.Hash{ours.Hash, theirs.Hash},
+		AllowEmptyCommits: true,
+	}
+	g.signing.apply(opts)
+	hash, err := w.Commit("Merge "+remote+" into local notes", opts)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// threeWayMerge compares ours and theirs against their merge base and returns the
+// worktree changes needed to merge theirs into ours, plus any unresolved conflicts.
+func threeWayMerge(ours, theirs *object.Commit, resolutions map[string]mergeResolution) ([]fileChange, []MergeConflict, error) {
+	var baseFiles map[string]*object.File
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bases) > 0 {
+		if baseFiles, err = commitFiles(bases[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	oursFiles, err := commitFiles(ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirsFiles, err := commitFiles(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, m := range []map[string]*object.File{baseFiles, oursFiles, theirsFiles} {
+		for p := range m {
+			paths[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var changes []fileChange
+	var conflicts []MergeConflict
+	for _, p := range sorted {
+		b, o, t := baseFiles[p], oursFiles[p], theirsFiles[p]
+		bh, oh, th := fileHash(b), fileHash(o), fileHash(t)
+
+		if oh == th || bh == th {
+			continue // identical on both sides, or only we changed it
+		}
+		if bh == oh {
+			// Only the remote changed it
+			if t == nil {
+				changes = append(changes, fileChange{path: p, delete: true})
+			} else {
+				content, err := t.Contents()
+				if err != nil {
+					return nil, nil, err
+				}
+				changes = append(changes, fileChange{path: p, content: []byte(content)})
+			}
+			continue
+		}
+
+		// Both sides changed the file differently
+		c := MergeConflict{Path: p, BaseExists: b != nil, OursExists: o != nil, TheirsExists: t != nil}
+		if c.Base, err = fileContents(b); err != nil {
+			return nil, nil, err
+		}
+		if c.Ours, err = fileContents(o); err != nil {
+			return nil, nil, err
+		}
+		if c.Theirs, err = fileContents(t); err != nil {
+			return nil, nil, err
+		}
+
+		if res, ok := resolutions[p]; ok {
+			switch res.Choice {
+			case "ours":
+				// Worktree already holds our version
+			case "theirs":
+				if t == nil {
+					changes = append(changes, fileChange{path: p, delete: true})
+				} else {
+					changes = append(changes, fileChange{path: p, content: []byte(c.Theirs)})
+				}
+			case "manual":
+				changes = append(changes, fileChange{path: p, content: []byte(res.Content)})
+			}
+			continue
+		}
+
+		switch {
+		case o == nil:
+			c.Kind = "delete/modify"
+		case t == nil:
+			c.Kind = "modify/delete"
+		case !isMergeable(p, c.Ours, c.Theirs):
+			c.Kind = "unmergeable"
+		default:
+			merged, conflicted := merge3(c.Base, c.Ours, c.Theirs, "remote ("+theirs.Hash.String()[:7]+")")
+			if !conflicted {
+				changes = append(changes, fileChange{path: p, content: []byte(merged)})
+				continue
+			}
+			c.Kind = "content"
+			if b == nil {
+				c.Kind = "add/add"
+			}
+			c.Merged = merged
+		}
+		conflicts = append(conflicts, c)
+	}
+	return changes, conflicts, nil
+}
+
+func commitFiles(c *object.Commit) (map[string]*object.File, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*object.File)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files[f.Name] = f
+		return nil
+	})
+	return files, err
+}
+
+func fileHash(f *object.File) plumbing.Hash {
+	if f == nil {
+		return plumbing.ZeroHash
+	}
+	return f.Hash
+}
+
+func fileContents(f *object.File) (string, error) {
+	if f == nil {
+		return "", nil
+	}
+	return f.Contents()
+}
+
+// isMergeable limits automatic line merging to markdown text.
+func isMergeable(path, ours, theirs string) bool {
+	if !strings.HasSuffix(strings.ToLower(path), ".md") {
+		return false
+	}
+	return !strings.ContainsRune(ours, 0) && !strings.ContainsRune(theirs, 0)
+}
+
+// merge3 performs a line-based three-way merge (diff3). Hunks changed on only one
+// side, or changed identically on both, are merged; overlapping hunks are
+// emitted between conflict markers and reported as conflicted.
+func merge3(base, ours, theirs, theirsLabel string) (string, bool) {
+	baseLines, oursLines, theirsLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	matchA := lineMatches(baseLines, oursLines)
+	matchB := lineMatches(baseLines, theirsLines)
+
+	var out strings.Builder
+	conflicted := false
+	writeLines := func(lines []string) {
+		for _, l := range lines {
+			out.WriteString(l)
+		}
+	}
+	writeBlock := func(lines []string) {
+		writeLines(lines)
+		if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	i, ja, jb := 0, 0, 0
+	for i < len(baseLines) || ja < len(oursLines) || jb < len(theirsLines) {
+		// Stable line present unchanged in all three versions
+		if i < len(baseLines) && matchA[i] == ja && matchB[i] == jb {
+			out.WriteString(baseLines[i])
+			i, ja, jb = i+1, ja+1, jb+1
+			continue
+		}
+
+		// Find the end of the unstable chunk: the next base line kept by both sides
+		k := i
+		for k < len(baseLines) && (matchA[k] < 0 || matchB[k] < 0) {
+			k++
+		}
+		ea, eb := len(oursLines), len(theirsLines)
+		if k < len(baseLines) {
+			ea, eb = matchA[k], matchB[k]
+		}
+
+		b, a, t := baseLines[i:k], oursLines[ja:ea], theirsLines[jb:eb]
+		switch {
+		case equalLines(a, b):
+			writeLines(t)
+		case equalLines(t, b), equalLines(a, t):
+			writeLines(a)
+		default:
+			conflicted = true
+			out.WriteString("<<<<<<< local\n")
+			writeBlock(a)
+			out.WriteString("=======\n")
+			writeBlock(t)
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+		i, ja, jb = k, ea, eb
+	}
+	return out.String(), conflicted
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
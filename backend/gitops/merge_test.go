@@ -0,0 +1,103 @@
+package gitops
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	const base = "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name           string
+		base           string
+		ours, theirs   string
+		want           string
+		wantConflicted bool
+	}{
+		{
+			name:   "edits to different lines both apply",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nc\nD\ne\n",
+			want:   "a\nB\nc\nD\ne\n",
+		},
+		{
+			name:   "the same edit on both sides applies once",
+			base:   base,
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nB\nc\nd\ne\n",
+			want:   "a\nB\nc\nd\ne\n",
+		},
+		{
+			name:   "a deletion on one side applies",
+			base:   base,
+			ours:   "a\nc\nd\ne\n",
+			theirs: base,
+			want:   "a\nc\nd\ne\n",
+		},
+		{
+			name:   "an append on one side applies",
+			base:   base,
+			ours:   base,
+			theirs: "a\nb\nc\nd\ne\nf\n",
+			want:   "a\nb\nc\nd\ne\nf\n",
+		},
+		{
+			name:           "different edits to one line conflict",
+			base:           base,
+			ours:           "a\nb\nX\nd\ne\n",
+			theirs:         "a\nb\nY\nd\ne\n",
+			want:           "a\nb\n<<<<<<< local\nX\n=======\nY\n>>>>>>> origin/main\nd\ne\n",
+			wantConflicted: true,
+		},
+		{
+			name:           "different appends conflict",
+			base:           base,
+			ours:           "a\nb\nc\nd\ne\nf\n",
+			theirs:         "a\nb\nc\nd\ne\ng\n",
+			want:           "a\nb\nc\nd\ne\n<<<<<<< local\nf\n=======\ng\n>>>>>>> origin/main\n",
+			wantConflicted: true,
+		},
+		{
+			name:           "conflict markers start on their own line without a final newline",
+			base:           "a\nb\nc",
+			ours:           "a\nb\nX",
+			theirs:         "a\nb\nY",
+			want:           "a\nb\n<<<<<<< local\nX\n=======\nY\n>>>>>>> origin/main\n",
+			wantConflicted: true,
+		},
+		{
+			name:   "a file added identically on both sides",
+			base:   "",
+			ours:   "new\n",
+			theirs: "new\n",
+			want:   "new\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicted := merge3(tt.base, tt.ours, tt.theirs, "origin/main")
+			if got != tt.want {
+				t.Errorf("merged:\n got %q\nwant %q", got, tt.want)
+			}
+			if conflicted != tt.wantConflicted {
+				t.Errorf("conflicted = %v, want %v", conflicted, tt.wantConflicted)
+			}
+		})
+	}
+}
+
+func TestIsMergeable(t *testing.T) {
+	tests := []struct {
+		path, ours, theirs string
+		want               bool
+	}{
+		{"notes/a.md", "x", "y", true},
+		{"notes/A.MD", "x", "y", true},
+		{"images/a.png", "x", "y", false},
+		{"notes/a.md", "x\x00", "y", false},
+	}
+	for _, tt := range tests {
+		if got := isMergeable(tt.path, tt.ours, tt.theirs); got != tt.want {
+			t.Errorf("isMergeable(%q, %q, %q) = %v, want %v", tt.path, tt.ours, tt.theirs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitCoalesceWindow is how long the queue waits after the first request for
+// more to arrive. Everything collected in the window lands in a single commit.
+const commitCoalesceWindow = 500 * time.Millisecond
+
+var errNothingStaged = errors.New("no files could be staged")
+
+type commitRequest struct {
+	files     []string // paths to stage; ignored when all is set
+	all       bool     // stage every change in the worktree
+	message   string
+	author    *object.Signature
+	committer *object.Signature
+	done      chan commitResult
+}
+
+type commitResult struct {
+	hash string
+	err  error
+}
+
+// enqueue hands a commit request to the queue and waits for the commit that
+// contains it. Requests coalesced into the same batch share one hash.
+func (g *GitManager) enqueue(ctx context.Context, req *commitRequest) (string, error) {
+	req.author, req.committer = g.signatures(ctx)
+	req.done = make(chan commitResult, 1)
+	g.queue <- req
+	res := <-req.done
+	return res.hash, res.err
+}
+
+// runQueue is the only goroutine that mutates the worktree and index, so
+// concurrent saves can no longer interleave pull/add/commit/push.
+func (g *GitManager) runQueue() {
+	for first := range g.queue {
+		batch := []*commitRequest{first}
+		timer := time.NewTimer(commitCoalesceWindow)
+	collect:
+		for {
+			select {
+			case req := <-g.queue:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+
+		// Changes by different authors never share a commit
+		groups := groupByAuthor(batch)
+		for i, group := range groups {
+			hash, err := g.commitBatch(group, claimedPaths(groups, i))
+			for _, req := range group {
+				req.done <- commitResult{hash: hash, err: err}
+			}
+		}
+	}
+}
+
+// groupByAuthor splits a batch by author. Groups that name their paths commit
+// before groups that stage everything, otherwise keeping first-arrival order.
+func groupByAuthor(batch []*commitRequest) [][]*commitRequest {
+	var groups [][]*commitRequest
+	index := make(map[string]int)
+	for _, req := range batch {
+		key := req.author.Name + "\x00" + req.author.Email
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], req)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return !stagesAll(groups[i]) && stagesAll(groups[j])
+	})
+	return groups
+}
+
+func stagesAll(group []*commitRequest) bool {
+	for _, req := range group {
+		if req.all {
+			return true
+		}
+	}
+	return false
+}
+
+// claimedPaths lists the paths named by every group in the batch except skip.
+// A group staging everything leaves them alone, so another author's change
+// never lands in its commit even if that author's own commit failed.
+func claimedPaths(groups [][]*commitRequest, skip int) []string {
+	var paths []string
+	for i, group := range groups {
+		if i == skip {
+			continue
+		}
+		for _, req := range group {
+			paths = append(paths, req.files...)
+		}
+	}
+	return paths
+}
+
+// stagePath stages a file or folder as it is on disk, including removals. A
+// folder that no longer exists is staged through the status of its entries.
+func stagePath(w *git.Worktree, path string, status git.Status) (int, error) {
+	if _, err := w.Filesystem.Lstat(path); err == nil {
+		if _, err := w.Add(path); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	staged := 0
+	for name := range status {
+		if !underPath(name, path) {
+			continue
+		}
+		if _, err := w.Add(name); err != nil {
+			return staged, err
+		}
+		staged++
+	}
+	return staged, nil
+}
+
+// underPath reports whether name is path itself or inside it.
+func underPath(name, path string) bool {
+	path = filepath.ToSlash(path)
+	return name == path || path == "." || strings.HasPrefix(name, path+"/")
+}
+
+func (g *GitManager) commitBatch(batch []*commitRequest, claimed []string) (string, error) {
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", err
+	}
+
+	w, err := g.worktree(repo)
+	if err != nil {
+		log.Printf("Error getting worktree: %v", err)
+		return "", err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		log.Printf("Error getting worktree status: %v", err)
+		return "", err
+	}
+
+	if stagesAll(batch) {
+		// Like git add --all, minus paths another author's request in this batch owns
+	changes:
+		for name := range status {
+			for _, p := range claimed {
+				if underPath(name, p) {
+					continue changes
+				}
+			}
+			if _, err := w.Add(name); err != nil {
+				log.Printf("Error adding %s to index: %v", name, err)
+				return "", err
+			}
+		}
+	} else {
+		staged := 0
+		for _, req := range batch {
+			for _, filename := range req.files {
+				n, err := stagePath(w, filename, status)
+				if err != nil {
+					log.Printf("Error adding file to index: %v", err)
+				}
+				staged += n
+			}
+		}
+		if staged == 0 {
+			return "", errNothingStaged
+		}
+	}
+
+	opts := &git.CommitOptions{
+		Author:    batch[0].author,
+		Committer: batch[0].committer,
+	}
+	g.signing.apply(opts)
+	hash, err := w.Commit(batchMessage(batch), opts)
+	if err == git.ErrEmptyCommit {
+		// Nothing changed since the last commit; not an error for callers
+		return "", nil
+	}
+	if err != nil {
+		log.Printf("Error committing: %v", err)
+		return "", err
+	}
+
+	// Remote sync happens in the background; just let the scheduler know
+	g.notifyCommitted()
+
+	return hash.String(), nil
+}
+
+// batchMessage keeps a single request's message as-is and summarises a batch
+// with one line per original message.
+func batchMessage(batch []*commitRequest) string {
+	if len(batch) == 1 {
+		return batch[0].message
+	}
+	seen := make(map[string]bool)
+	var lines []string
+	for _, req := range batch {
+		if !seen[req.message] {
+			seen[req.message] = true
+			lines = append(lines, "- "+req.message)
+		}
+	}
+	if len(lines) == 1 {
+		return batch[0].message
+	}
+	return fmt.Sprintf("Batch update (%d changes)\n\n%s", len(lines), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,327 @@
+package gitops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+var validRemoteName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Remote is one configured sync target. The first configured remote is the
+// primary: it is pulled from and used for ahead/behind counts. Every remote with
+// push enabled receives pushes.
+type Remote struct {
+	Name             string `json:"name"`
+	URL              string `json:"url"`
+	Username         string `json:"username,omitempty"`
+	Token            string `json:"token,omitempty"`
+	TokenFile        string `json:"token_file,omitempty"`
+	SSHKey           string `json:"ssh_key,omitempty"`
+	SSHKeyPassphrase string `json:"ssh_key_passphrase,omitempty"`
+	KnownHosts       string `json:"known_hosts,omitempty"`
+	Push             *bool  `json:"push,omitempty"` // defaults to true
+}
+
+// RemoteInfo is the secret-free description of a remote returned by the API.
+type RemoteInfo struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Auth    string `json:"auth"` // "ssh-key", "ssh-agent", "token", "gh", "none"
+	Push    bool   `json:"push"`
+	Primary bool   `json:"primary"`
+}
+
+// RemoteCheck is the result of testing connectivity to one remote.
+type RemoteCheck struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// LoadRemotes reads the remote configuration. GIT_REMOTES_FILE points at a JSON
+// array of remotes (suitable for a mounted secrets file). Otherwise a single
+// remote is built from GIT_REMOTE_URL (or the legacy GITHUB_REPO) with
+// credentials from GIT_USERNAME, GIT_TOKEN / GIT_TOKEN_FILE, GIT_SSH_KEY,
+// GIT_SSH_KEY_PASSPHRASE and GIT_SSH_KNOWN_HOSTS.
+func LoadRemotes() ([]Remote, error) {
+	if path := os.Getenv("GIT_REMOTES_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading GIT_REMOTES_FILE: %w", err)
+		}
+		var remotes []Remote
+		if err := json.Unmarshal(data, &remotes); err != nil {
+			return nil, fmt.Errorf("parsing GIT_REMOTES_FILE: %w", err)
+		}
+		seen := make(map[string]bool)
+		for i, r := range remotes {
+			if r.Name == "" {
+				remotes[i].Name = "origin"
+				if i > 0 {
+					return nil, fmt.Errorf("remote %d in GIT_REMOTES_FILE has no name", i)
+				}
+			}
+			if !validRemoteName.MatchString(remotes[i].Name) {
+				return nil, fmt.Errorf("invalid remote name %q", remotes[i].Name)
+			}
+			if seen[remotes[i].Name] {
+				return nil, fmt.Errorf("duplicate remote name %q", remotes[i].Name)
+			}
+			seen[remotes[i].Name] = true
+			if r.URL == "" {
+				return nil, fmt.Errorf("remote %q has no url", remotes[i].Name)
+			}
+		}
+		return remotes, nil
+	}
+
+	repoURL := os.Getenv("GIT_REMOTE_URL")
+	if repoURL == "" {
+		repoURL = os.Getenv("GITHUB_REPO")
+	}
+	if repoURL == "" {
+		return nil, nil
+	}
+	name := os.Getenv("GIT_REMOTE_NAME")
+	if name == "" {
+		name = "origin"
+	}
+	return []Remote{{
+		Name:             name,
+		URL:              repoURL,
+		Username:         os.Getenv("GIT_USERNAME"),
+		Token:            os.Getenv("GIT_TOKEN"),
+		TokenFile:        os.Getenv("GIT_TOKEN_FILE"),
+		SSHKey:           os.Getenv("GIT_SSH_KEY"),
+		SSHKeyPassphrase: os.Getenv("GIT_SSH_KEY_PASSPHRASE"),
+		KnownHosts:       os.Getenv("GIT_SSH_KNOWN_HOSTS"),
+	}}, nil
+}
+
+// remotesConfigured reports whether any remote is set up, ignoring config errors.
+func remotesConfigured() bool {
+	remotes, err := LoadRemotes()
+	return err == nil && len(remotes) > 0
+}
+
+// PushEnabled reports whether this remote receives pushes.
+func (r Remote) PushEnabled() bool {
+	return r.Push == nil || *r.Push
+}
+
+func (r Remote) token() string {
+	if r.Token != "" {
+		return r.Token
+	}
+	if r.TokenFile != "" {
+		if data, err := os.ReadFile(r.TokenFile); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// authKind names the auth method AuthMethod would use, without loading secrets.
+func (r Remote) authKind() string {
+	ep, err := transport.NewEndpoint(r.URL)
+	if err != nil {
+		return "none"
+	}
+	switch ep.Protocol {
+	case "ssh":
+		if r.SSHKey != "" {
+			return "ssh-key"
+		}
+		return "ssh-agent"
+	case "http", "https":
+		if r.token() != "" || ep.Password != "" {
+			return "token"
+		}
+		if ep.Host == "github.com" {
+			return "gh"
+		}
+	}
+	return "none"
+}
+
+// AuthMethod builds go-git credentials for the remote. SSH remotes use the
+// configured key (or the SSH agent) and verify the host against known_hosts;
+// HTTP remotes use token auth, falling back to `gh auth token` for github.com.
+// Local paths and file:// URLs need no auth.
+func (r Remote) AuthMethod() (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(r.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ep.Protocol {
+	case "ssh":
+		user := ep.User
+		if user == "" {
+			user = r.Username
+		}
+		if user == "" {
+			user = "git"
+		}
+		var hostKeys gitssh.HostKeyCallbackHelper
+		if r.KnownHosts != "" {
+			cb, err := gitssh.NewKnownHostsCallback(r.KnownHosts)
+			if err != nil {
+				return nil, fmt.Errorf("loading known_hosts: %w", err)
+			}
+			hostKeys.HostKeyCallback = cb
+		}
+		// With no explicit file go-git checks SSH_KNOWN_HOSTS or ~/.ssh/known_hosts
+		if r.SSHKey != "" {
+			keys, err := gitssh.NewPublicKeysFromFile(user, r.SSHKey, r.SSHKeyPassphrase)
+			if err != nil {
+				return nil, fmt.Errorf("loading ssh key: %w", err)
+			}
+			keys.HostKeyCallbackHelper = hostKeys
+			return keys, nil
+		}
+		agent, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("no ssh key configured and ssh agent unavailable: %w", err)
+		}
+		agent.HostKeyCallbackHelper = hostKeys
+		return agent, nil
+
+	case "http", "https":
+		token := r.token()
+		if token == "" && ep.Password != "" {
+			return nil, nil // credentials embedded in the URL
+		}
+		if token == "" && ep.Host == "github.com" {
+			token = getGHToken()
+		}
+		if token == "" {
+			return nil, nil
+		}
+		user := r.Username
+		if user == "" {
+			user = ep.User
+		}
+		if user == "" {
+			user = "git"
+		}
+		return &http.BasicAuth{Username: user, Password: token}, nil
+	}
+	return nil, nil
+}
+
+// DisplayURL strips any password from the URL so it can be logged or returned.
+func (r Remote) DisplayURL() string {
+	if !strings.Contains(r.URL, "://") {
+		return r.URL
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil || u.User == nil {
+		return r.URL
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// DescribeRemotes lists the configured remotes without credentials.
+func DescribeRemotes() ([]RemoteInfo, error) {
+	remotes, err := LoadRemotes()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]RemoteInfo, 0, len(remotes))
+	for i, r := range remotes {
+		infos = append(infos, RemoteInfo{
+			Name:    r.Name,
+			URL:     r.DisplayURL(),
+			Auth:    r.authKind(),
+			Push:    r.PushEnabled(),
+			Primary: i == 0,
+		})
+	}
+	return infos, nil
+}
+
+// ensureRemote creates the remote in the repository config, or updates its URL
+// if the configuration changed.
+func ensureRemote(repo *git.Repository, r Remote) error {
+	existing, err := repo.Remote(r.Name)
+	if errors.Is(err, git.ErrRemoteNotFound) {
+		_, err = repo.CreateRemote(&config.RemoteConfig{
+			Name: r.Name,
+			URLs: []string{r.URL},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	urls := existing.Config().URLs
+	if len(urls) == 1 && urls[0] == r.URL {
+		return nil
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Remotes[r.Name].URLs = []string{r.URL}
+	return repo.SetConfig(cfg)
+}
+
+// CheckRemotes verifies connectivity and credentials for every configured remote
+// by listing its references.
+func (g *GitManager) CheckRemotes() ([]RemoteCheck, error) {
+	remotes, err := LoadRemotes()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make([]RemoteCheck, 0, len(remotes))
+	for _, r := range remotes {
+		check := RemoteCheck{Name: r.Name, URL: r.DisplayURL()}
+		err := func() error {
+			g.repoMu.Lock()
+			err := ensureRemote(repo, r)
+			g.repoMu.Unlock()
+			if err != nil {
+				return err
+			}
+			auth, err := r.AuthMethod()
+			if err != nil {
+				return err
+			}
+			remote, err := repo.Remote(r.Name)
+			if err != nil {
+				return err
+			}
+			_, err = remote.List(&git.ListOptions{Auth: auth})
+			if err == transport.ErrEmptyRemoteRepository {
+				return nil
+			}
+			return err
+		}()
+		if err != nil {
+			check.Error = err.Error()
+		} else {
+			check.Success = true
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
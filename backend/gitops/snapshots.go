@@ -0,0 +1,310 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// snapshotTagPrefix namespaces snapshot tags so other tags in the repo are ignored.
+const snapshotTagPrefix = "snapshot/"
+
+// snapshotRefSpec pushes snapshot tags alongside the branch.
+const snapshotRefSpec = config.RefSpec("+refs/tags/snapshot/*:refs/tags/snapshot/*")
+
+var (
+	ErrSnapshotExists   = errors.New("snapshot already exists")
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+
+	snapshotSlugStrip = regexp.MustCompile(`[^a-z0-9._-]+`)
+	validSnapshotID   = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+)
+
+// Snapshot is a named point in vault history, stored as an annotated tag
+// refs/tags/snapshot/<id>.
+type Snapshot struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Message string    `json:"message"`
+	Commit  string    `json:"commit"`
+	Tagger  string    `json:"tagger"`
+	Date    time.Time `json:"date"`
+}
+
+// SnapshotID turns a display name like "Q3 planning frozen" into the tag-safe
+// id "q3-planning-frozen".
+func SnapshotID(name string) string {
+	id := snapshotSlugStrip.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+	return strings.Trim(id, "-.")
+}
+
+// ValidSnapshotID reports whether id can name a snapshot tag.
+func ValidSnapshotID(id string) bool {
+	return validSnapshotID.MatchString(id) && !strings.Contains(id, "..") && !strings.HasSuffix(id, ".lock")
+}
+
+// CreateSnapshot commits any outstanding changes and tags the resulting HEAD.
+// The first line of the tag message is the display name.
+func (g *GitManager) CreateSnapshot(ctx context.Context, name, message string) (Snapshot, error) {
+	id := SnapshotID(name)
+	if !ValidSnapshotID(id) {
+		return Snapshot{}, fmt.Errorf("invalid snapshot name %q", name)
+	}
+
+	if repo, err := g.openRepo(); err == nil {
+		if _, err := repo.Tag(snapshotTagPrefix + id); err == nil {
+			return Snapshot{}, ErrSnapshotExists
+		}
+	}
+
+	// Make sure the snapshot captures what is on disk right now
+	if _, err := g.CommitAll(ctx, "Snapshot: "+name); err != nil {
+		return Snapshot{}, err
+	}
+
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("vault has no commits to snapshot: %w", err)
+	}
+	if _, err := repo.Tag(snapshotTagPrefix + id); err == nil {
+		return Snapshot{}, ErrSnapshotExists
+	}
+
+	tagger, _ := g.signatures(ctx)
+	tagMessage := name
+	if strings.TrimSpace(message) != "" {
+		tagMessage += "\n\n" + strings.TrimSpace(message)
+	}
+	ref, err := repo.CreateTag(snapshotTagPrefix+id, head.Hash(), &git.CreateTagOptions{
+		Tagger:  tagger,
+		Message: tagMessage,
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	// Tags travel with the next push
+	g.notifyCommitted()
+
+	tag, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return snapshotFromTag(id, tag), nil
+}
+
+func snapshotFromTag(id string, tag *object.Tag) Snapshot {
+	name, message, _ := strings.Cut(strings.TrimSpace(tag.Message), "\n")
+	return Snapshot{
+		ID:      id,
+		Name:    name,
+		Message: strings.TrimSpace(message),
+		Commit:  tag.Target.String(),
+		Tagger:  tag.Tagger.Name,
+		Date:    tag.Tagger.When,
+	}
+}
+
+// ListSnapshots returns all snapshots, newest first.
+func (g *GitManager) ListSnapshots() ([]Snapshot, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		id, ok := strings.CutPrefix(ref.Name().Short(), snapshotTagPrefix)
+		if !ok {
+			return nil
+		}
+		tag, err := repo.TagObject(ref.Hash())
+		if err != nil {
+			return nil // lightweight tag; not created by us
+		}
+		snapshots = append(snapshots, snapshotFromTag(id, tag))
+		return nil
+	})
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.After(snapshots[j].Date)
+	})
+	return snapshots, err
+}
+
+// snapshotTag resolves a snapshot id to its annotated tag.
+func (g *GitManager) snapshotTag(repo *git.Repository, id string) (*object.Tag, error) {
+	ref, err := repo.Tag(snapshotTagPrefix + id)
+	if err != nil {
+		return nil, ErrSnapshotNotFound
+	}
+	tag, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		return nil, ErrSnapshotNotFound
+	}
+	return tag, nil
+}
+
+// snapshotCommit resolves a snapshot id to its tagged commit.
+func (g *GitManager) snapshotCommit(repo *git.Repository, id string) (*object.Commit, error) {
+	tag, err := g.snapshotTag(repo, id)
+	if err != nil {
+		return nil, err
+	}
+	return tag.Commit()
+}
+
+// SnapshotFiles lists the files in a snapshot, optionally limited to a folder.
+func (g *GitManager) SnapshotFiles(id, folder string) (Snapshot, []string, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	tag, err := g.snapshotTag(repo, id)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	c, err := tag.Commit()
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+
+	folder = strings.Trim(filepath.ToSlash(folder), "/")
+	var files []string
+	iter, err := c.Files()
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	err = iter.ForEach(func(f *object.File) error {
+		if inFolder(f.Name, folder) {
+			files = append(files, f.Name)
+		}
+		return nil
+	})
+	return snapshotFromTag(id, tag), files, err
+}
+
+// SnapshotFileContent returns a file's content as of a snapshot.
+func (g *GitManager) SnapshotFileContent(id, filename string) (string, error) {
+	repo, err := g.openRepo()
+	if err != nil {
+		return "", err
+	}
+	c, err := g.snapshotCommit(repo, id)
+	if err != nil {
+		return "", err
+	}
+	f, err := c.File(filepath.ToSlash(filename))
+	if err != nil {
+		return "", ErrFileNotFound
+	}
+	return f.Contents()
+}
+
+// RestoreSnapshot rewrites a folder (or the whole vault when folder is empty) to
+// match a snapshot and records the result as a new commit, so the restore is
+// itself reversible. Files added since the snapshot are removed. App state and
+// the recycle bin are left alone.
+func (g *GitManager) RestoreSnapshot(ctx context.Context, id, folder string) (string, error) {
+	folder = strings.Trim(filepath.ToSlash(folder), "/")
+	if err := g.writeSnapshotFiles(id, folder); err != nil {
+		return "", err
+	}
+
+	// The queue takes repoMu itself
+	if folder == "" {
+		return g.CommitAll(ctx, fmt.Sprintf("Restore vault to snapshot %s", id))
+	}
+	return g.CommitFile(ctx, folder, fmt.Sprintf("Restore %s to snapshot %s", folder, id))
+}
+
+// writeSnapshotFiles rewrites the worktree under folder to match the snapshot.
+// It holds repoMu throughout so the commit queue and a background merge never
+// see the files half restored.
+func (g *GitManager) writeSnapshotFiles(id, folder string) error {
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo, err := g.openRepo()
+	if err != nil {
+		return err
+	}
+	c, err := g.snapshotCommit(repo, id)
+	if err != nil {
+		return err
+	}
+	restorable := func(name string) bool {
+		return inFolder(name, folder) && !g.isAppStateFile(name) && !inFolder(name, recycleBinDir)
+	}
+
+	target := make(map[string]*object.File)
+	iter, err := c.Files()
+	if err != nil {
+		return err
+	}
+	if err := iter.ForEach(func(f *object.File) error {
+		if restorable(f.Name) {
+			target[f.Name] = f
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Remove tracked files that did not exist at the snapshot
+	if head, err := repo.Head(); err == nil {
+		headCommit, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		headFiles, err := headCommit.Files()
+		if err != nil {
+			return err
+		}
+		if err := headFiles.ForEach(func(f *object.File) error {
+			if restorable(f.Name) && target[f.Name] == nil {
+				if err := os.Remove(filepath.Join(g.dataDir, filepath.FromSlash(f.Name))); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	for name, f := range target {
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		fullPath := filepath.Join(g.dataDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
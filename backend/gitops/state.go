@@ -0,0 +1,365 @@
+package gitops
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// defaultStateDirName is the state directory inside DATA_DIR when STATE_DIR is
+// unset. It is excluded from git, so it persists with the vault volume without
+// being committed.
+const defaultStateDirName = ".asdf"
+
+// Paths inside the state directory.
+const (
+	stateImagesDir     = "images"
+	stateRecycleBinDir = "recycle_bin"
+	stateSyncConfig    = "git_config.json"
+)
+
+// legacyState maps app files that used to live in the vault root to their
+// place in the state directory. An empty target means the file is left for its
+// own migration and ignored meanwhile.
+var legacyState = []struct {
+	vaultPath string
+	statePath string
+}{
+	{".git_config.json", stateSyncConfig},
+	{".images", stateImagesDir},
+	{recycleBinDir, stateRecycleBinDir},
+	{legacySyncLogFile, ""},
+}
+
+const (
+	excludeBegin = "# BEGIN asdf managed: app state, do not edit"
+	excludeEnd   = "# END asdf managed"
+)
+
+// resolveStateDir returns STATE_DIR, or the default directory inside dataDir.
+func resolveStateDir(dataDir string) string {
+	if dir := os.Getenv("STATE_DIR"); dir != "" {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(dataDir, defaultStateDirName)
+}
+
+// StateDir is where app-internal files (sync settings, recycle bin, uploaded
+// images) live, outside version control.
+func (g *GitManager) StateDir() string {
+	return g.stateDir
+}
+
+// ImagesDir holds uploaded images served under /images/.
+func (g *GitManager) ImagesDir() string {
+	return filepath.Join(g.stateDir, stateImagesDir)
+}
+
+// RecycleBinDir holds deleted notes and folders until they are restored or
+// purged.
+func (g *GitManager) RecycleBinDir() string {
+	return filepath.Join(g.stateDir, stateRecycleBinDir)
+}
+
+// SyncConfigPath is the settings file toggled from the UI.
+func (g *GitManager) SyncConfigPath() string {
+	return filepath.Join(g.stateDir, stateSyncConfig)
+}
+
+// stateDirInVault returns the state directory relative to the vault in slash
+// form, or "" when it lives elsewhere.
+func (g *GitManager) stateDirInVault() string {
+	rel, err := filepath.Rel(g.dataDir, g.stateDir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// IsStatePath reports whether a vault-relative path belongs to app state
+// rather than notes, including the legacy in-vault locations.
+func (g *GitManager) IsStatePath(rel string) bool {
+	rel = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(rel)), "./")
+	if dir := g.stateDirInVault(); dir != "" && inFolder(rel, dir) {
+		return true
+	}
+	for _, s := range legacyState {
+		if inFolder(rel, s.vaultPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludePatterns lists what the managed block in .git/info/exclude ignores.
+func (g *GitManager) excludePatterns() []string {
+	var patterns []string
+	if dir := g.stateDirInVault(); dir != "" {
+		patterns = append(patterns, "/"+dir+"/")
+	}
+	for _, s := range legacyState {
+		patterns = append(patterns, "/"+s.vaultPath)
+	}
+	return patterns
+}
+
+// worktree returns the repository worktree with the app state policy applied.
+// go-git does not read .git/info/exclude through the worktree filesystem, so
+// the patterns are set on the worktree as well.
+func (g *GitManager) worktree(repo *git.Repository) (*git.Worktree, error) {
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range g.excludePatterns() {
+		w.Excludes = append(w.Excludes, gitignore.ParsePattern(p, nil))
+	}
+	return w, nil
+}
+
+// writeExcludes keeps the managed block in .git/info/exclude current, leaving
+// any user entries outside it untouched. The exclude file is never committed,
+// so the policy does not leak into the notes repository.
+func (g *GitManager) writeExcludes() error {
+	path := filepath.Join(g.dataDir, ".git", "info", "exclude")
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimRight(string(existing), "\n"), "\n") {
+		switch {
+		case line == excludeBegin:
+			inBlock = true
+		case line == excludeEnd:
+			inBlock = false
+		case !inBlock && (line != "" || len(kept) > 0):
+			kept = append(kept, line)
+		}
+	}
+
+	block := append([]string{excludeBegin}, g.excludePatterns()...)
+	block = append(block, excludeEnd)
+	if len(kept) > 0 {
+		block = append(block, "")
+	}
+	content := strings.Join(append(block, kept...), "\n") + "\n"
+	if content == string(existing) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// prepareState creates the state directory, installs the exclude policy and
+// migrates app files left in the vault by earlier versions. It is safe to run
+// on every start; once nothing is left to move it does nothing.
+func (g *GitManager) prepareState() {
+	if err := os.MkdirAll(g.stateDir, 0755); err != nil {
+		log.Printf("Error creating state directory %s: %v", g.stateDir, err)
+		return
+	}
+
+	g.repoMu.Lock()
+	defer g.repoMu.Unlock()
+
+	repo := g.InitRepo()
+	if repo == nil {
+		return
+	}
+	if err := g.writeExcludes(); err != nil {
+		log.Printf("Error writing git exclude file: %v", err)
+	}
+
+	for _, s := range legacyState {
+		src := filepath.Join(g.dataDir, s.vaultPath)
+		if _, err := os.Lstat(src); err != nil {
+			continue
+		}
+		if s.statePath == "" {
+			continue // left for its own migration, and ignored meanwhile
+		}
+		if err := moveInto(src, filepath.Join(g.stateDir, s.statePath)); err != nil {
+			log.Printf("Error moving %s to the state directory: %v", s.vaultPath, err)
+			continue
+		}
+		log.Printf("Moved %s to the state directory %s", s.vaultPath, g.stateDir)
+	}
+
+	if err := g.untrackState(repo); err != nil {
+		log.Printf("Error removing app state from the git index: %v", err)
+	}
+}
+
+// moveInto moves src to dst. When both are directories the entries of src are
+// merged into dst; entries that already exist in dst are left in src.
+func moveInto(src, dst string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return MovePath(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	if !srcInfo.IsDir() || !dstInfo.IsDir() {
+		return fmt.Errorf("%s already exists", dst)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		target := filepath.Join(dst, e.Name())
+		if _, err := os.Lstat(target); err == nil {
+			log.Printf("Leaving %s in place: %s already exists", filepath.Join(src, e.Name()), target)
+			continue
+		}
+		if err := MovePath(filepath.Join(src, e.Name()), target); err != nil {
+			return err
+		}
+	}
+	// Only removes src if everything moved
+	os.Remove(src)
+	return nil
+}
+
+// MovePath renames src to dst like os.Rename. STATE_DIR may be on another
+// filesystem than DATA_DIR, where a rename fails with EXDEV; the move then
+// falls back to copying src and removing it.
+func MovePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	var linkErr *os.LinkError
+	if err == nil || !errors.As(err, &linkErr) || !errors.Is(linkErr.Err, syscall.EXDEV) {
+		return err
+	}
+	return copyAndRemove(src, dst)
+}
+
+// copyAndRemove copies a file or folder next to dst, keeping modes and
+// modification times, renames the copy into place and then removes src. A
+// partial copy is cleaned up on failure and never appears at dst.
+func copyAndRemove(src, dst string) error {
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), ".move-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	staged := filepath.Join(tmp, filepath.Base(dst))
+
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(staged, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			if err := copyFile(p, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		default:
+			return fmt.Errorf("cannot move %s: not a regular file", p)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(staged, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// untrackState drops app state from the index, like git rm --cached, and
+// commits the removal. Files on disk are not touched.
+func (g *GitManager) untrackState(repo *git.Repository) error {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for _, e := range idx.Entries {
+		if g.IsStatePath(e.Name) {
+			removed = append(removed, e.Name)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	for _, name := range removed {
+		if _, err := idx.Remove(name); err != nil {
+			return err
+		}
+	}
+	if err := repo.Storer.SetIndex(idx); err != nil {
+		return err
+	}
+
+	w, err := g.worktree(repo)
+	if err != nil {
+		return err
+	}
+	committer := g.getAuthorSignature()
+	opts := &git.CommitOptions{Author: committer, Committer: committer}
+	g.signing.apply(opts)
+	if _, err := w.Commit("Move app state out of the vault", opts); err != nil {
+		return err
+	}
+	log.Printf("Removed %d app state files from the git index", len(removed))
+	g.notifyCommitted()
+	return nil
+}
@@ -0,0 +1,55 @@
+package gitops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCopyAndRemove covers the fallback MovePath takes across filesystems.
+func TestCopyAndRemove(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "vault", "projects")
+	if err := os.MkdirAll(filepath.Join(src, "q3"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(src, "q3", "plan.md")
+	if err := os.WriteFile(note, []byte("plan\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(note, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("plan.md", filepath.Join(src, "q3", "latest.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(root, "state", "recycle_bin")
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(bin, "projects")
+	if err := copyAndRemove(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists: %v", err)
+	}
+	moved := filepath.Join(dst, "q3", "plan.md")
+	info, err := os.Stat(moved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 || !info.ModTime().Equal(modified) {
+		t.Errorf("mode %v, modified %v; want 0600, %v", info.Mode().Perm(), info.ModTime(), modified)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "q3", "latest.md")); err != nil || link != "plan.md" {
+		t.Errorf("symlink = %q, %v", link, err)
+	}
+	if entries, _ := os.ReadDir(bin); len(entries) != 1 {
+		t.Errorf("recycle bin has %d entries, want only the moved folder", len(entries))
+	}
+}
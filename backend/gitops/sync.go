@@ -0,0 +1,389 @@
+package gitops
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	defaultSyncInterval = 5 * time.Minute
+	defaultPushIdle     = 30 * time.Second
+	syncBackoffBase     = 30 * time.Second
+	syncBackoffMax      = 30 * time.Minute
+
+	// reachableLimit caps the commit walk when there is no upstream to stop at
+	reachableLimit = 10000
+)
+
+// syncState tracks the background scheduler so status can be reported without
+// touching the network.
+type syncState struct {
+	mu          sync.Mutex
+	lastSync    time.Time
+	lastError   string
+	failures    int
+	nextAttempt time.Time
+	interval    time.Duration
+	pushIdle    time.Duration
+	running     bool
+	counts      syncCounts // last read by GetSyncStatus
+}
+
+// syncCounts is the part of SyncStatus read from the repository.
+type syncCounts struct {
+	pending      int
+	ahead        int
+	behind       int
+	remoteBranch string
+}
+
+// SyncStatus is the background sync summary returned by GET /api/git/status.
+type SyncStatus struct {
+	Running      bool       `json:"running"`
+	Interval     string     `json:"interval"`
+	PushIdle     string     `json:"push_idle"`
+	Pending      int        `json:"pending"` // uncommitted changes in the worktree
+	Ahead        int        `json:"ahead"`   // local commits not yet pushed
+	Behind       int        `json:"behind"`  // fetched remote commits not yet merged
+	LastSync     *time.Time `json:"last_sync"`
+	LastError    string     `json:"last_error"`
+	Failures     int        `json:"consecutive_failures"`
+	NextAttempt  *time.Time `json:"next_attempt"`
+	BackingOff   bool       `json:"backing_off"`
+	RemoteBranch string     `json:"remote_branch"`
+}
+
+// StartSync launches the background pull/push scheduler. Remote sync runs every
+// GIT_SYNC_INTERVAL (default 5m, "0" disables periodic sync) and GIT_PUSH_IDLE
+// (default 30s) after the last commit. Failures back off exponentially.
+func (g *GitManager) StartSync() {
+	g.sync.interval = envDuration("GIT_SYNC_INTERVAL", defaultSyncInterval)
+	g.sync.pushIdle = envDuration("GIT_PUSH_IDLE", defaultPushIdle)
+
+	if !remotesConfigured() {
+		return
+	}
+	log.Printf("GitHub Sync: background sync every %s, push after %s idle", g.sync.interval, g.sync.pushIdle)
+	g.sync.mu.Lock()
+	g.sync.running = true
+	g.sync.mu.Unlock()
+	go g.syncLoop()
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	if v == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("Invalid %s %q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// notifyCommitted tells the scheduler a commit landed so it can push once idle.
+func (g *GitManager) notifyCommitted() {
+	select {
+	case g.committed <- struct{}{}:
+	default:
+	}
+}
+
+func (g *GitManager) syncLoop() {
+	var intervalC <-chan time.Time
+	if g.sync.interval > 0 {
+		ticker := time.NewTicker(g.sync.interval)
+		defer ticker.Stop()
+		intervalC = ticker.C
+	}
+
+	idle := time.NewTimer(0)
+	if !idle.Stop() {
+		<-idle.C
+	}
+	retry := time.NewTimer(0)
+	if !retry.Stop() {
+		<-retry.C
+	}
+
+	// Pull once at startup so the vault starts from the latest remote state
+	g.runScheduledSync(retry)
+
+	for {
+		select {
+		case <-g.committed:
+			if g.sync.pushIdle > 0 {
+				idle.Reset(g.sync.pushIdle)
+			}
+		case <-idle.C:
+			g.runScheduledSync(retry)
+		case <-intervalC:
+			g.runScheduledSync(retry)
+		case <-retry.C:
+			g.runScheduledSync(retry)
+		case done := <-g.syncNow:
+			done <- g.syncOnce()
+		}
+	}
+}
+
+// runScheduledSync syncs unless the scheduler is backing off, and arms the retry
+// timer after a failure.
+func (g *GitManager) runScheduledSync(retry *time.Timer) {
+	g.sync.mu.Lock()
+	wait := time.Until(g.sync.nextAttempt)
+	g.sync.mu.Unlock()
+	if wait > 0 {
+		return
+	}
+
+	if err := g.syncOnce(); err != nil {
+		g.sync.mu.Lock()
+		wait = time.Until(g.sync.nextAttempt)
+		g.sync.mu.Unlock()
+		retry.Reset(wait)
+	}
+}
+
+// SyncNow runs a pull and push immediately, bypassing any backoff.
+func (g *GitManager) SyncNow() error {
+	g.sync.mu.Lock()
+	running := g.sync.running
+	g.sync.mu.Unlock()
+	if !running {
+		return g.syncOnce()
+	}
+	done := make(chan error, 1)
+	g.syncNow <- done
+	return <-done
+}
+
+// syncOnce pulls then pushes under the repository lock and records the outcome.
+func (g *GitManager) syncOnce() error {
+	if g.isSyncDisabled() {
+		return nil
+	}
+
+	g.repoMu.Lock()
+	repo, err := g.openRepo()
+	if err == nil {
+		err = g.pullFromRemote(repo)
+		// A diverged branch cannot be pushed until its conflicts are resolved
+		if !errors.Is(err, ErrMergeConflicts) && !errors.Is(err, ErrMergeDeferred) {
+			err = errors.Join(err, g.pushToRemote(repo))
+		}
+	}
+	g.repoMu.Unlock()
+	if errors.Is(err, ErrMergeDeferred) {
+		// Not a failure: the pending commit triggers the next sync
+		log.Printf("GitHub Sync: %v", err)
+		return nil
+	}
+
+	g.sync.mu.Lock()
+	defer g.sync.mu.Unlock()
+	g.sync.lastSync = time.Now()
+	if err != nil {
+		g.sync.failures++
+		g.sync.lastError = err.Error()
+		backoff := syncBackoffBase << (g.sync.failures - 1)
+		if backoff > syncBackoffMax || backoff <= 0 {
+			backoff = syncBackoffMax
+		}
+		g.sync.nextAttempt = time.Now().Add(backoff)
+		log.Printf("GitHub Sync: background sync failed (%d in a row), retrying in %s", g.sync.failures, backoff)
+		return err
+	}
+	g.sync.failures = 0
+	g.sync.lastError = ""
+	g.sync.nextAttempt = time.Time{}
+	return nil
+}
+
+// GetSyncStatus reports scheduler state plus pending/ahead/behind counts.
+func (g *GitManager) GetSyncStatus() SyncStatus {
+	g.sync.mu.Lock()
+	status := SyncStatus{
+		Running:   g.sync.running,
+		Interval:  g.sync.interval.String(),
+		PushIdle:  g.sync.pushIdle.String(),
+		LastError: g.sync.lastError,
+		Failures:  g.sync.failures,
+	}
+	if !g.sync.lastSync.IsZero() {
+		t := g.sync.lastSync
+		status.LastSync = &t
+	}
+	if !g.sync.nextAttempt.IsZero() {
+		t := g.sync.nextAttempt
+		status.NextAttempt = &t
+		status.BackingOff = time.Now().Before(t)
+	}
+	g.sync.mu.Unlock()
+
+	// Commits, merges and syncs hold repoMu, a sync for a whole network round
+	// trip. Rather than wait, report the counts from the last read meanwhile.
+	if g.repoMu.TryLock() {
+		counts := g.readSyncCounts()
+		g.repoMu.Unlock()
+		g.sync.mu.Lock()
+		g.sync.counts = counts
+		g.sync.mu.Unlock()
+	}
+	g.sync.mu.Lock()
+	counts := g.sync.counts
+	g.sync.mu.Unlock()
+	status.Pending = counts.pending
+	status.Ahead = counts.ahead
+	status.Behind = counts.behind
+	status.RemoteBranch = counts.remoteBranch
+	return status
+}
+
+// readSyncCounts counts uncommitted changes and the commits ahead of and behind
+// the primary remote's branch. Callers must hold repoMu.
+func (g *GitManager) readSyncCounts() syncCounts {
+	var counts syncCounts
+	repo, err := g.openRepo()
+	if err != nil {
+		return counts
+	}
+
+	if w, err := g.worktree(repo); err == nil {
+		if st, err := w.Status(); err == nil {
+			for _, fs := range st {
+				if fs.Worktree != ' ' || fs.Staging != ' ' {
+					counts.pending++
+				}
+			}
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil || !head.Name().IsBranch() {
+		return counts
+	}
+	remoteName := "origin"
+	if remotes, err := LoadRemotes(); err == nil && len(remotes) > 0 {
+		remoteName = remotes[0].Name
+	}
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, head.Name().Short())
+	counts.remoteBranch = remoteRef.Short()
+
+	remote, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		// Never pushed: every local commit is ahead
+		counts.ahead = len(reachableCommits(repo, head.Hash()))
+		return counts
+	}
+	counts.ahead, counts.behind = aheadBehind(repo, head.Hash(), remote.Hash())
+	return counts
+}
+
+// aheadBehind counts the commits reachable from local but not remote, and from
+// remote but not local. Commits reachable from both are the merge bases and
+// their ancestors, so each side is walked from its tip down to the bases.
+// Usually that is all: when neither walk reaches a root commit, everything it
+// saw descends from a base and so is not shared. Only when a walk gets past
+// the bases, e.g. through a merge of a branch forked below them, are the
+// ancestors of the bases walked to rule out what is shared after all. Commit
+// dates play no part, so clock skew cannot throw the counts off.
+func aheadBehind(repo *git.Repository, local, remote plumbing.Hash) (ahead, behind int) {
+	if local == remote {
+		return 0, 0
+	}
+	l, err := repo.CommitObject(local)
+	if err != nil {
+		return 0, 0
+	}
+	r, err := repo.CommitObject(remote)
+	if err != nil {
+		return 0, 0
+	}
+	bases, err := l.MergeBase(r)
+	if err != nil {
+		return 0, 0
+	}
+	var stop []plumbing.Hash
+	for _, b := range bases {
+		stop = append(stop, b.Hash)
+	}
+
+	onlyLocal, localClosed := commitsAbove(l, stop)
+	onlyRemote, remoteClosed := commitsAbove(r, stop)
+	if localClosed && remoteClosed {
+		return len(onlyLocal), len(onlyRemote)
+	}
+
+	shared := make(map[plumbing.Hash]bool)
+	for _, b := range bases {
+		for h := range reachableCommits(repo, b.Hash) {
+			shared[h] = true
+		}
+	}
+	for h := range onlyLocal {
+		if !shared[h] {
+			ahead++
+		}
+	}
+	for h := range onlyRemote {
+		if !shared[h] {
+			behind++
+		}
+	}
+	return ahead, behind
+}
+
+// commitsAbove returns the commits reachable from tip without passing through
+// stop, capped at reachableLimit. closed reports that the walk ended at stop
+// commits only, i.e. met no root commit and no cap.
+func commitsAbove(tip *object.Commit, stop []plumbing.Hash) (map[plumbing.Hash]bool, bool) {
+	seen := make(map[plumbing.Hash]bool)
+	closed := true
+	iter := object.NewCommitPreorderIter(tip, nil, stop)
+	defer iter.Close()
+	iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		if len(c.ParentHashes) == 0 {
+			closed = false
+		}
+		if len(seen) >= reachableLimit {
+			closed = false
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return seen, closed
+}
+
+// reachableCommits returns the set of commits reachable from tip, capped at reachableLimit.
+func reachableCommits(repo *git.Repository, tip plumbing.Hash) map[plumbing.Hash]bool {
+	seen := make(map[plumbing.Hash]bool)
+	c, err := repo.CommitObject(tip)
+	if err != nil {
+		return seen
+	}
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	defer iter.Close()
+	iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		if len(seen) >= reachableLimit {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return seen
+}
@@ -0,0 +1,194 @@
+package gitops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// commitSpec describes one commit of a test history: its parents by name and
+// its committer time in minutes.
+type commitSpec struct {
+	name    string
+	parents []string
+	minute  int
+}
+
+// buildHistory stores the commits, in order, in an in-memory repository.
+func buildHistory(t *testing.T, specs []commitSpec) (*git.Repository, map[string]plumbing.Hash) {
+	t.Helper()
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := make(map[string]plumbing.Hash)
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, spec := range specs {
+		sig := object.Signature{Name: "test", Email: "test@example.com", When: start.Add(time.Duration(spec.minute) * time.Minute)}
+		c := &object.Commit{Author: sig, Committer: sig, Message: spec.name, TreeHash: tree}
+		for _, p := range spec.parents {
+			c.ParentHashes = append(c.ParentHashes, hashes[p])
+		}
+		obj := repo.Storer.NewEncodedObject()
+		if err := c.Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+		if hashes[spec.name], err = repo.Storer.SetEncodedObject(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo, hashes
+}
+
+func TestAheadBehind(t *testing.T) {
+	tests := []struct {
+		name          string
+		history       []commitSpec
+		local, remote string
+		ahead, behind int
+	}{
+		{
+			name:    "same commit",
+			history: []commitSpec{{"a", nil, 0}},
+			local:   "a", remote: "a",
+		},
+		{
+			name:    "linear, local ahead",
+			history: []commitSpec{{"a", nil, 0}, {"b", []string{"a"}, 1}, {"c", []string{"b"}, 2}},
+			local:   "c", remote: "a",
+			ahead: 2,
+		},
+		{
+			name:    "linear, local behind",
+			history: []commitSpec{{"a", nil, 0}, {"b", []string{"a"}, 1}, {"c", []string{"b"}, 2}},
+			local:   "a", remote: "c",
+			behind: 2,
+		},
+		{
+			name: "diverged",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"l1", []string{"a"}, 1}, {"l2", []string{"l1"}, 3},
+				{"r1", []string{"a"}, 2}, {"r2", []string{"r1"}, 4}, {"r3", []string{"r2"}, 5},
+			},
+			local: "l2", remote: "r3",
+			ahead: 2, behind: 3,
+		},
+		{
+			name: "remote merged into local, then moved on",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"l1", []string{"a"}, 1},
+				{"r1", []string{"a"}, 2},
+				{"m", []string{"l1", "r1"}, 3},
+				{"r2", []string{"r1"}, 4},
+			},
+			local: "m", remote: "r2",
+			ahead: 2, behind: 1,
+		},
+		{
+			name: "merge of an old side branch",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"s1", []string{"a"}, 1},
+				{"b", []string{"a"}, 2}, {"c", []string{"b"}, 3},
+				{"m", []string{"c", "s1"}, 4},
+			},
+			local: "m", remote: "c",
+			ahead: 2,
+		},
+		{
+			name: "side branch merged on both sides",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"s1", []string{"a"}, 1}, {"s2", []string{"s1"}, 2},
+				{"b", []string{"a"}, 3},
+				{"l1", []string{"b", "s1"}, 4},
+				{"r1", []string{"b", "s2"}, 5},
+			},
+			local: "l1", remote: "r1",
+			ahead: 1, behind: 2,
+		},
+		{
+			name: "unrelated histories",
+			history: []commitSpec{
+				{"l1", nil, 0}, {"l2", []string{"l1"}, 1},
+				{"r1", nil, 2},
+			},
+			local: "l2", remote: "r1",
+			ahead: 2, behind: 1,
+		},
+		{
+			name: "equal timestamps",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"l1", []string{"a"}, 0}, {"l2", []string{"l1"}, 0},
+				{"r1", []string{"a"}, 0}, {"r2", []string{"r1"}, 0},
+			},
+			local: "l2", remote: "r2",
+			ahead: 2, behind: 2,
+		},
+		{
+			name: "local commit dated before the base",
+			history: []commitSpec{
+				{"a", nil, 10},
+				{"l1", []string{"a"}, 0},
+				{"r1", []string{"a"}, 20},
+			},
+			local: "l1", remote: "r1",
+			ahead: 1, behind: 1,
+		},
+		{
+			name: "shared ancestor dated after the counted commits",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"b", []string{"a"}, 100},
+				{"c", []string{"b"}, 5},
+				{"l1", []string{"c"}, 6},
+				{"r1", []string{"c"}, 7},
+			},
+			local: "l1", remote: "r1",
+			ahead: 1, behind: 1,
+		},
+		{
+			name: "skewed commit reachable from both through a merge",
+			history: []commitSpec{
+				{"a", nil, 0},
+				{"old", []string{"a"}, 50},
+				{"b", []string{"a"}, 1},
+				{"r1", []string{"b", "old"}, 2},
+				{"l1", []string{"r1"}, 3},
+				{"l2", []string{"l1"}, 60},
+			},
+			local: "l2", remote: "r1",
+			ahead: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, hashes := buildHistory(t, tt.history)
+			ahead, behind := aheadBehind(repo, hashes[tt.local], hashes[tt.remote])
+			if ahead != tt.ahead || behind != tt.behind {
+				t.Errorf("ahead, behind = %d, %d, want %d, %d", ahead, behind, tt.ahead, tt.behind)
+			}
+			// commitsBetween counts what a pull brought in or a push sent
+			if n := commitsBetween(repo, hashes[tt.remote], hashes[tt.local]); n != tt.ahead {
+				t.Errorf("commitsBetween = %d, want %d", n, tt.ahead)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package gitops
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// defaultSyncLogRetention is how long sync events are kept when
+// GIT_SYNC_LOG_RETENTION is unset.
+const defaultSyncLogRetention = 30 * 24 * time.Hour
+
+// legacySyncLogFile is where sync events used to be written, inside the vault.
+const legacySyncLogFile = ".git_sync_log.json"
+
+// Sync operations recorded in the log.
+const (
+	SyncOpPull  = "pull"
+	SyncOpMerge = "merge"
+	SyncOpPush  = "push"
+)
+
+// Error classes group sync failures by what the user has to fix.
+const (
+	SyncErrAuth     = "auth"
+	SyncErrHostKey  = "host_key"
+	SyncErrPrompt   = "prompt"
+	SyncErrNetwork  = "network"
+	SyncErrNotFound = "not_found"
+	SyncErrConflict = "conflict"
+	SyncErrRejected = "rejected"
+	SyncErrOther    = "other"
+)
+
+// SyncLogEntry is one pull, merge or push attempt.
+type SyncLogEntry struct {
+	ID             int64     `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Action         string    `json:"action"` // display label, e.g. "Push backup"
+	Operation      string    `json:"operation"`
+	Remote         string    `json:"remote"`
+	Success        bool      `json:"success"`
+	DurationMs     int64     `json:"duration_ms"`
+	Commits        int       `json:"commits"` // commits pulled or pushed
+	ErrorClass     string    `json:"error_class"`
+	Error          string    `json:"error"`
+	Recommendation string    `json:"recommendation"`
+}
+
+// SyncLogFilter narrows GetSyncLogs. Zero values match everything.
+type SyncLogFilter struct {
+	Operation  string
+	Remote     string
+	ErrorClass string
+	Success    *bool
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// syncAction is the label older clients show for an entry.
+func syncAction(operation, remote string) string {
+	if operation == "" {
+		return ""
+	}
+	action := strings.ToUpper(operation[:1]) + operation[1:]
+	if remote != "" && remote != "origin" {
+		action += " " + remote
+	}
+	return action
+}
+
+// classifySyncError returns the error class and a recommendation for err.
+func classifySyncError(err error) (class, recommendation string) {
+	if errors.Is(err, ErrMergeConflicts) {
+		return SyncErrConflict, "Conflicts detected. Review them via GET /api/git/conflicts and resolve each with POST /api/git/conflicts/resolve."
+	}
+	lowerErr := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(lowerErr, "knownhosts") || strings.Contains(lowerErr, "host key"):
+		return SyncErrHostKey, "SSH host key verification failed. Add the server to known_hosts (GIT_SSH_KNOWN_HOSTS) and retry."
+	case strings.Contains(lowerErr, "auth") || strings.Contains(lowerErr, "authentication") || strings.Contains(lowerErr, "401") || strings.Contains(lowerErr, "credentials"):
+		return SyncErrAuth, "Authentication failed. Check GIT_TOKEN/GIT_SSH_KEY for the remote, or run 'gh auth login --insecure-storage' on the host for GitHub."
+	case strings.Contains(lowerErr, "username") || strings.Contains(lowerErr, "terminal") || strings.Contains(lowerErr, "prompt"):
+		return SyncErrPrompt, "Interactive prompt requested. Configure a token or SSH key for the remote so no prompt is needed."
+	case strings.Contains(lowerErr, "resolve") || strings.Contains(lowerErr, "dial tcp") || strings.Contains(lowerErr, "timeout"):
+		return SyncErrNetwork, "Network connection failed. Verify the server has internet access and your proxy/DNS settings are correct."
+	case strings.Contains(lowerErr, "not found") || strings.Contains(lowerErr, "404"):
+		return SyncErrNotFound, "Repository not found. Double check the remote URL and ensure the repository exists on the server."
+	case strings.Contains(lowerErr, "non-fast-forward") || strings.Contains(lowerErr, "merge"):
+		return SyncErrRejected, "The remote has changes this vault does not. The next pull will merge them; if it keeps failing, check GET /api/git/conflicts."
+	}
+	return SyncErrOther, "Check the remote URL and credentials, and run POST /api/git/check for diagnostics."
+}
+
+// logSync records one sync attempt that began at start. Without a database the
+// event only goes to the process log.
+func (g *GitManager) logSync(operation, remote string, start time.Time, commits int, syncErr error) {
+	e := SyncLogEntry{
+		Timestamp:  start,
+		Operation:  operation,
+		Remote:     remote,
+		Success:    syncErr == nil,
+		DurationMs: time.Since(start).Milliseconds(),
+		Commits:    commits,
+	}
+	if syncErr != nil {
+		e.Error = syncErr.Error()
+		e.ErrorClass, e.Recommendation = classifySyncError(syncErr)
+	}
+	if g.db == nil {
+		return
+	}
+	if err := insertSyncLog(g.db, e); err != nil {
+		log.Printf("GitHub Sync: failed to record sync log: %v", err)
+		return
+	}
+	g.pruneSyncLogs()
+}
+
+func insertSyncLog(db *sql.DB, e SyncLogEntry) error {
+	_, err := db.Exec(`
+		INSERT INTO git_sync_log (created_at, operation, remote, success, duration_ms, commits, error_class, error, recommendation)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, e.Timestamp, e.Operation, e.Remote, e.Success, e.DurationMs, e.Commits, e.ErrorClass, e.Error, e.Recommendation)
+	return err
+}
+
+// pruneSyncLogs drops events older than GIT_SYNC_LOG_RETENTION ("0" keeps
+// everything).
+func (g *GitManager) pruneSyncLogs() {
+	retention := envDuration("GIT_SYNC_LOG_RETENTION", defaultSyncLogRetention)
+	if retention == 0 {
+		return
+	}
+	if _, err := g.db.Exec(`DELETE FROM git_sync_log WHERE created_at < $1`, time.Now().Add(-retention)); err != nil {
+		log.Printf("GitHub Sync: failed to prune sync log: %v", err)
+	}
+}
+
+// GetSyncLogs returns sync events newest first.
+func (g *GitManager) GetSyncLogs(f SyncLogFilter) ([]SyncLogEntry, error) {
+	entries := []SyncLogEntry{}
+	if g.db == nil {
+		return entries, nil
+	}
+
+	var where []string
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Operation != "" {
+		add("operation = $%d", f.Operation)
+	}
+	if f.Remote != "" {
+		add("remote = $%d", f.Remote)
+	}
+	if f.ErrorClass != "" {
+		add("error_class = $%d", f.ErrorClass)
+	}
+	if f.Success != nil {
+		add("success = $%d", *f.Success)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at <= $%d", f.Until)
+	}
+
+	query := `SELECT id, created_at, operation, remote, success, duration_ms, commits, error_class, error, recommendation FROM git_sync_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if f.Offset > 0 {
+		args = append(args, f.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := g.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e SyncLogEntry
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.Operation, &e.Remote, &e.Success, &e.DurationMs, &e.Commits, &e.ErrorClass, &e.Error, &e.Recommendation); err != nil {
+			return nil, err
+		}
+		e.Action = syncAction(e.Operation, e.Remote)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// migrateSyncLogFile moves events from the old in-vault .git_sync_log.json into
+// the database and deletes the file, so the next commit drops it from the repo.
+func (g *GitManager) migrateSyncLogFile() {
+	logPath := filepath.Join(g.dataDir, legacySyncLogFile)
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		return
+	}
+
+	var legacy []struct {
+		Timestamp      string `json:"timestamp"`
+		Action         string `json:"action"`
+		Success        bool   `json:"success"`
+		Error          string `json:"error"`
+		Recommendation string `json:"recommendation"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		log.Printf("GitHub Sync: ignoring unreadable %s: %v", legacySyncLogFile, err)
+	}
+	for _, l := range legacy {
+		ts, err := time.Parse(time.RFC3339, l.Timestamp)
+		if err != nil {
+			continue
+		}
+		op, remote, _ := strings.Cut(l.Action, " ")
+		e := SyncLogEntry{
+			Timestamp:      ts,
+			Operation:      strings.ToLower(op),
+			Remote:         remote,
+			Success:        l.Success,
+			Error:          l.Error,
+			Recommendation: l.Recommendation,
+		}
+		if !l.Success && l.Error != "" {
+			e.ErrorClass, _ = classifySyncError(errors.New(l.Error))
+		}
+		if err := insertSyncLog(g.db, e); err != nil {
+			log.Printf("GitHub Sync: failed to migrate %s: %v", legacySyncLogFile, err)
+			return
+		}
+	}
+	if err := os.Remove(logPath); err != nil {
+		log.Printf("GitHub Sync: failed to remove %s: %v", legacySyncLogFile, err)
+		return
+	}
+	log.Printf("GitHub Sync: moved %d sync log entries from %s to the database", len(legacy), legacySyncLogFile)
+}
+
+// commitsBetween counts commits reachable from to but not from from. A zero
+// from counts everything reachable from to.
+func commitsBetween(repo *git.Repository, from, to plumbing.Hash) int {
+	if to.IsZero() || from == to {
+		return 0
+	}
+	if from.IsZero() {
+		return len(reachableCommits(repo, to))
+	}
+	n, _ := aheadBehind(repo, to, from)
+	return n
+}
+
+// headHash is HEAD's commit, or the zero hash in an empty repository.
+func headHash(repo *git.Repository) plumbing.Hash {
+	head, err := repo.Head()
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return head.Hash()
+}
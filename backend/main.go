@@ -17,6 +17,7 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/leraptor65/simple-data-flow/api"
+	"github.com/leraptor65/simple-data-flow/gitops"
 	"github.com/leraptor65/simple-data-flow/watcher"
 )
 
@@ -61,11 +62,17 @@ func main() {
 	os.MkdirAll(dataDir, 0755)
 
 	// Start Watcher
+	watcher.ApplySearchLanguage(db)
 	w := watcher.NewWatcher(db, dataDir)
 	w.Start()
 
+	// A single git manager serializes every commit against the vault repository
+	gitMgr := gitops.NewGitManager(db, dataDir)
+	gitMgr.StartSync()
+
 	// Setup API
-	a := api.NewAPI(db, dataDir)
+	a := api.NewAPI(db, dataDir, gitMgr)
+	r.Use(a.IdentityMiddleware)
 	a.RegisterRoutes(r)
 
 	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
@@ -131,6 +138,15 @@ func initDB() {
 }
 
 func createSchema(db *sql.DB) {
+	// pg_trgm powers fuzzy search but creating it may need rights the app's role
+	// lacks. Run it on its own so a failure cannot roll back the schema; search
+	// then works without fuzzy matching.
+	trigrams := true
+	if _, err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm"); err != nil {
+		log.Printf("WARNING: pg_trgm extension unavailable, fuzzy search is disabled: %v", err)
+		trigrams = false
+	}
+
 	schema := `
 	CREATE TABLE IF NOT EXISTS notes (
 		id SERIAL PRIMARY KEY,
@@ -139,10 +155,20 @@ func createSchema(db *sql.DB) {
 		frontmatter JSONB,
 		content TEXT,
 		content_vector tsvector,
-		last_modified TIMESTAMP
+		last_modified TIMESTAMP,
+		content_hash TEXT,
+		size BIGINT,
+		mtime TIMESTAMP
 	);
 
+	ALTER TABLE notes ADD COLUMN IF NOT EXISTS content_hash TEXT;
+	ALTER TABLE notes ADD COLUMN IF NOT EXISTS size BIGINT;
+	ALTER TABLE notes ADD COLUMN IF NOT EXISTS mtime TIMESTAMP;
+	ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_version INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_config regconfig NOT NULL DEFAULT 'english';
+
 	CREATE INDEX IF NOT EXISTS notes_content_vector_idx ON notes USING GIN(content_vector);
+	CREATE INDEX IF NOT EXISTS notes_frontmatter_idx ON notes USING GIN(frontmatter);
 
 	CREATE TABLE IF NOT EXISTS links (
 		source_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
@@ -150,6 +176,42 @@ func createSchema(db *sql.DB) {
 		PRIMARY KEY (source_id, target_id)
 	);
 
+	CREATE TABLE IF NOT EXISTS note_tags (
+		note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (note_id, tag)
+	);
+
+	CREATE INDEX IF NOT EXISTS note_tags_tag_idx ON note_tags(tag text_pattern_ops);
+
+	-- Text search language per folder; the empty folder holds the vault-wide default
+	CREATE TABLE IF NOT EXISTS search_languages (
+		folder TEXT PRIMARY KEY,
+		config regconfig NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS git_sync_log (
+		id BIGSERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		operation TEXT NOT NULL,
+		remote TEXT NOT NULL DEFAULT '',
+		success BOOLEAN NOT NULL,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		commits INTEGER NOT NULL DEFAULT 0,
+		error_class TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		recommendation TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS git_sync_log_created_at_idx ON git_sync_log(created_at DESC);
+
+	CREATE TABLE IF NOT EXISTS saved_searches (
+		id SERIAL PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		query TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
 	CREATE TABLE IF NOT EXISTS shared_links (
 		id SERIAL PRIMARY KEY,
 		token TEXT UNIQUE NOT NULL,
@@ -164,45 +226,67 @@ func createSchema(db *sql.DB) {
 	} else {
 		log.Println("Database schema initialized.")
 	}
+
+	if trigrams {
+		if _, err := db.Exec(`
+		CREATE INDEX IF NOT EXISTS notes_filename_trgm_idx ON notes USING GIN(filename gin_trgm_ops);
+		CREATE INDEX IF NOT EXISTS notes_title_trgm_idx ON notes USING GIN(title gin_trgm_ops);
+		CREATE INDEX IF NOT EXISTS notes_content_trgm_idx ON notes USING GIN(content gin_trgm_ops);
+		`); err != nil {
+			log.Printf("Error creating trigram indexes: %v", err)
+		}
+	}
 }
 
 func verifyGitIntegration() {
-	repo := os.Getenv("GITHUB_REPO")
-
-	if repo == "" {
-		log.Println("GitHub Sync: optional GitHub integration is disabled (GITHUB_REPO not set).")
+	remotes, err := gitops.DescribeRemotes()
+	if err != nil {
+		log.Printf("GitHub Sync: WARNING: invalid remote configuration: %v", err)
+		return
+	}
+	if len(remotes) == 0 {
+		log.Println("GitHub Sync: optional remote sync is disabled (GIT_REMOTE_URL / GITHUB_REPO not set).")
 		return
 	}
 
-	log.Printf("GitHub Sync: optional GitHub integration is enabled for repo: %s", repo)
+	usesGH := false
+	for _, r := range remotes {
+		log.Printf("GitHub Sync: remote %s -> %s (auth: %s, push: %t)", r.Name, r.URL, r.Auth, r.Push)
+		if r.Auth == "gh" {
+			usesGH = true
+		}
+	}
 
 	hasWarnings := false
 
-	// Check if gh CLI is installed
-	if _, err := exec.LookPath("gh"); err != nil {
-		log.Println("GitHub Sync: WARNING: GitHub CLI (gh) is not installed. Authentication will not work.")
-		hasWarnings = true
-	} else {
-		// Check gh auth status
-		cmd := exec.Command("gh", "auth", "status")
-		var combined bytes.Buffer
-		cmd.Stdout = &combined
-		cmd.Stderr = &combined
-		if err := cmd.Run(); err != nil {
-			log.Println("GitHub Sync: WARNING: Not authenticated with gh. Run 'gh auth login' on the host machine.")
+	// Only GitHub remotes without their own token rely on the gh CLI
+	if usesGH {
+		// Check if gh CLI is installed
+		if _, err := exec.LookPath("gh"); err != nil {
+			log.Println("GitHub Sync: WARNING: GitHub CLI (gh) is not installed. Authentication will not work.")
 			hasWarnings = true
 		} else {
-			log.Println("GitHub Sync: gh auth is active.")
-		}
-
-		// Check token availability
-		tokenCmd := exec.Command("gh", "auth", "token")
-		var tokenOut bytes.Buffer
-		tokenCmd.Stdout = &tokenOut
-		tokenCmd.Stderr = nil
-		if err := tokenCmd.Run(); err != nil || strings.TrimSpace(tokenOut.String()) == "" {
-			log.Println("GitHub Sync: WARNING: No token available from 'gh auth token'. Push/pull will fail.")
-			hasWarnings = true
+			// Check gh auth status
+			cmd := exec.Command("gh", "auth", "status")
+			var combined bytes.Buffer
+			cmd.Stdout = &combined
+			cmd.Stderr = &combined
+			if err := cmd.Run(); err != nil {
+				log.Println("GitHub Sync: WARNING: Not authenticated with gh. Run 'gh auth login' on the host machine.")
+				hasWarnings = true
+			} else {
+				log.Println("GitHub Sync: gh auth is active.")
+			}
+
+			// Check token availability
+			tokenCmd := exec.Command("gh", "auth", "token")
+			var tokenOut bytes.Buffer
+			tokenCmd.Stdout = &tokenOut
+			tokenCmd.Stderr = nil
+			if err := tokenCmd.Run(); err != nil || strings.TrimSpace(tokenOut.String()) == "" {
+				log.Println("GitHub Sync: WARNING: No token available from 'gh auth token'. Push/pull will fail.")
+				hasWarnings = true
+			}
 		}
 	}
 
@@ -225,7 +309,7 @@ func verifyGitIntegration() {
 	}
 
 	if hasWarnings {
-		log.Println("GitHub Sync: Setup completed with warnings. Check your gh auth and volume mounts.")
+		log.Println("GitHub Sync: Setup completed with warnings. Check your remote credentials and volume mounts.")
 	} else {
 		log.Println("GitHub Sync: Setup successfully completed with active credentials.")
 	}
@@ -23,3 +23,10 @@ type SharedLink struct {
 	ExpiresAt *time.Time `json:"expires_at"` // nil = never expires
 	CreatedAt time.Time  `json:"created_at"`
 }
+
+type SavedSearch struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+}
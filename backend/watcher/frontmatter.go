@@ -0,0 +1,147 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseFrontmatter splits a note into its frontmatter block and body.
+// YAML blocks are fenced with "---" and TOML blocks with "+++"; both must start
+// on the first line of the file. Notes without frontmatter return a nil map and
+// the original content as body.
+func ParseFrontmatter(content string) (map[string]interface{}, string, error) {
+	// Ignore a UTF-8 BOM so editors that add one don't hide the block
+	text := strings.TrimPrefix(content, "\ufeff")
+
+	var delim string
+	switch {
+	case strings.HasPrefix(text, "---"):
+		delim = "---"
+	case strings.HasPrefix(text, "+++"):
+		delim = "+++"
+	default:
+		return nil, content, nil
+	}
+
+	firstNL := strings.Index(text, "\n")
+	if firstNL < 0 || strings.TrimSpace(text[:firstNL]) != delim {
+		return nil, content, nil
+	}
+
+	// Find the closing fence on a line of its own
+	rest := text[firstNL+1:]
+	offset := 0
+	end := -1
+	bodyStart := -1
+	for offset <= len(rest) {
+		lineEnd := strings.Index(rest[offset:], "\n")
+		var line string
+		if lineEnd < 0 {
+			line = rest[offset:]
+		} else {
+			line = rest[offset : offset+lineEnd]
+		}
+		if strings.TrimRight(line, " \t\r") == delim {
+			end = offset
+			if lineEnd < 0 {
+				bodyStart = len(rest)
+			} else {
+				bodyStart = offset + lineEnd + 1
+			}
+			break
+		}
+		if lineEnd < 0 {
+			break
+		}
+		offset += lineEnd + 1
+	}
+	if end < 0 {
+		// Unterminated fence: treat the whole file as body (e.g. a leading horizontal rule)
+		return nil, content, nil
+	}
+
+	block := rest[:end]
+	body := rest[bodyStart:]
+
+	fm := make(map[string]interface{})
+	var err error
+	if delim == "---" {
+		err = yaml.Unmarshal([]byte(block), &fm)
+	} else {
+		_, err = toml.Decode(block, &fm)
+	}
+	if err != nil {
+		return nil, content, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	if fm == nil {
+		fm = make(map[string]interface{})
+	}
+
+	return normalizeFrontmatter(fm).(map[string]interface{}), body, nil
+}
+
+// FrontmatterJSON encodes parsed frontmatter for the notes.frontmatter JSONB column.
+func FrontmatterJSON(fm map[string]interface{}) string {
+	if len(fm) == 0 {
+		return "{}"
+	}
+	data, err := json.Marshal(fm)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// normalizeFrontmatter converts decoded YAML/TOML values into JSON-friendly types.
+// Dates become ISO-8601 strings so they sort and compare correctly in Postgres.
+func normalizeFrontmatter(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = normalizeFrontmatter(item)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[fmt.Sprint(k)] = normalizeFrontmatter(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeFrontmatter(item)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = normalizeFrontmatter(item)
+		}
+		return out
+	case time.Time:
+		if val.Hour() == 0 && val.Minute() == 0 && val.Second() == 0 && val.Nanosecond() == 0 {
+			return val.Format("2006-01-02")
+		}
+		return val.Format(time.RFC3339)
+	default:
+		return val
+	}
+}
+
+// frontmatterString returns a trimmed string value for key, or "" if absent.
+func frontmatterString(fm map[string]interface{}, key string) string {
+	if fm == nil {
+		return ""
+	}
+	if s, ok := fm[key].(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package watcher
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debounceDelay is how long a path must stay quiet before it is indexed. Editors
+// typically emit several Write events per save; they collapse into one operation.
+const debounceDelay = 300 * time.Millisecond
+
+// indexWorkers bounds how many files are read and upserted concurrently, so a
+// git pull touching hundreds of files does not flood Postgres.
+const indexWorkers = 4
+
+type opKind int
+
+const (
+	opIndex opKind = iota
+	opRemove
+)
+
+type pendingOp struct {
+	kind        opKind
+	renamedFrom string // set when a Create was paired with an earlier Remove/Rename
+	isDir       bool
+	timer       *time.Timer
+}
+
+// eventQueue coalesces filesystem events per path and hands settled paths to a
+// fixed pool of workers. A path is never processed by two workers at once.
+type eventQueue struct {
+	w        *Watcher
+	mu       sync.Mutex
+	pending  map[string]*pendingOp
+	inFlight map[string]bool
+	work     chan string
+}
+
+func newEventQueue(w *Watcher) *eventQueue {
+	q := &eventQueue{
+		w:        w,
+		pending:  make(map[string]*pendingOp),
+		inFlight: make(map[string]bool),
+		work:     make(chan string, indexWorkers*4),
+	}
+	for i := 0; i < indexWorkers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// Index schedules a markdown file to be (re)indexed once writes settle.
+func (q *eventQueue) Index(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.scheduleLocked(path, opIndex, "", false)
+}
+
+// Remove schedules removal of a file or folder. The removal is held for the
+// debounce window so a following Create can claim it as a rename.
+func (q *eventQueue) Remove(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.scheduleLocked(path, opRemove, "", false)
+}
+
+// Created handles a Create event. If it pairs with a pending removal it becomes a
+// rename, which keeps the note ID (and therefore its links and tags) intact.
+func (q *eventQueue) Created(path string, isDir bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Moves into hidden folders such as .recycle_bin are plain removals
+	if q.w.isHidden(path) {
+		return
+	}
+
+	from := q.matchRemovalLocked(path, isDir)
+	if from != "" {
+		op := q.pending[from]
+		op.timer.Stop()
+		delete(q.pending, from)
+	}
+
+	if isDir {
+		if from != "" {
+			q.scheduleLocked(path, opIndex, from, true)
+		}
+		return
+	}
+	q.scheduleLocked(path, opIndex, from, false)
+}
+
+func (q *eventQueue) scheduleLocked(path string, kind opKind, renamedFrom string, isDir bool) {
+	if op, ok := q.pending[path]; ok {
+		op.kind = kind
+		if renamedFrom != "" {
+			op.renamedFrom = renamedFrom
+			op.isDir = isDir
+		}
+		op.timer.Reset(debounceDelay)
+		return
+	}
+	op := &pendingOp{kind: kind, renamedFrom: renamedFrom, isDir: isDir}
+	op.timer = time.AfterFunc(debounceDelay, func() { q.dispatch(path) })
+	q.pending[path] = op
+}
+
+// matchRemovalLocked finds the pending removal a Create most likely belongs to:
+// one with the same base name (a move between folders), otherwise the only
+// pending removal of the same kind (a rename in place). Either way the indexed
+// content must match what was created, so deleting one note while creating
+// another is not mistaken for a rename.
+func (q *eventQueue) matchRemovalLocked(path string, isDir bool) string {
+	var candidates []string
+	for p, op := range q.pending {
+		if op.kind != opRemove || p == path {
+			continue
+		}
+		if strings.HasSuffix(p, ".md") == isDir {
+			continue
+		}
+		if filepath.Base(p) == filepath.Base(path) {
+			if q.w.sameContent(p, path, isDir) {
+				return p
+			}
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+	if len(candidates) == 1 && q.w.sameContent(candidates[0], path, isDir) {
+		return candidates[0]
+	}
+	return ""
+}
+
+func (q *eventQueue) dispatch(path string) {
+	q.mu.Lock()
+	if _, ok := q.pending[path]; !ok {
+		q.mu.Unlock()
+		return
+	}
+	if q.inFlight[path] {
+		// Another worker is still handling this path; try again after it settles
+		q.pending[path].timer.Reset(debounceDelay)
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+	q.work <- path
+}
+
+func (q *eventQueue) worker() {
+	for path := range q.work {
+		q.mu.Lock()
+		op, ok := q.pending[path]
+		if !ok {
+			q.mu.Unlock()
+			continue
+		}
+		if q.inFlight[path] {
+			op.timer.Reset(debounceDelay)
+			q.mu.Unlock()
+			continue
+		}
+		delete(q.pending, path)
+		q.inFlight[path] = true
+		q.mu.Unlock()
+
+		q.run(path, op)
+
+		q.mu.Lock()
+		delete(q.inFlight, path)
+		q.mu.Unlock()
+	}
+}
+
+func (q *eventQueue) run(path string, op *pendingOp) {
+	switch op.kind {
+	case opRemove:
+		log.Println("Removed path:", path)
+		q.w.RemoveFile(path)
+	case opIndex:
+		if op.renamedFrom != "" {
+			log.Printf("Renamed path: %s -> %s", op.renamedFrom, path)
+			if op.isDir {
+				q.w.RenameFolder(op.renamedFrom, path)
+				return
+			}
+			q.w.RenameFile(op.renamedFrom, path)
+		} else {
+			log.Println("Modified file:", path)
+		}
+		q.w.ProcessFile(path)
+	}
+}
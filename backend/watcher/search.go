@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// searchIndexVersion identifies how content_vector is built. Bump it when the
+// recipe changes so existing rows are re-indexed on the next sync.
+const searchIndexVersion = 2
+
+// DefaultSearchLanguage is the text search configuration used when
+// SEARCH_LANGUAGE is unset.
+const DefaultSearchLanguage = "english"
+
+// languageCodes maps ISO 639-1 codes to Postgres text search configurations.
+var languageCodes = map[string]string{
+	"ar": "arabic", "ca": "catalan", "da": "danish", "de": "german", "el": "greek",
+	"en": "english", "es": "spanish", "eu": "basque", "fi": "finnish", "fr": "french",
+	"ga": "irish", "hi": "hindi", "hu": "hungarian", "hy": "armenian", "id": "indonesian",
+	"it": "italian", "lt": "lithuanian", "nb": "norwegian", "ne": "nepali", "nl": "dutch",
+	"nn": "norwegian", "no": "norwegian", "pt": "portuguese", "ro": "romanian", "ru": "russian",
+	"sr": "serbian", "sv": "swedish", "ta": "tamil", "tr": "turkish", "yi": "yiddish",
+}
+
+// SearchConfig resolves a language name or code ("german", "de", "de-CH") to a
+// text search configuration installed in the database.
+func SearchConfig(db *sql.DB, lang string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(lang))
+	code, _, _ := strings.Cut(strings.ReplaceAll(name, "_", "-"), "-")
+	if cfg, ok := languageCodes[code]; ok {
+		name = cfg
+	}
+	var cfg string
+	err := db.QueryRow("SELECT cfgname FROM pg_ts_config WHERE cfgname = $1", name).Scan(&cfg)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("unknown text search language %q", lang)
+	}
+	return cfg, err
+}
+
+// ApplySearchLanguage records the vault-wide language from SEARCH_LANGUAGE as
+// the search_languages row for the empty folder. When it differs from the one
+// notes were indexed with, every note is re-indexed on the next sync.
+func ApplySearchLanguage(db *sql.DB) {
+	lang := os.Getenv("SEARCH_LANGUAGE")
+	if lang == "" {
+		lang = DefaultSearchLanguage
+	}
+	cfg, err := SearchConfig(db, lang)
+	if err != nil {
+		log.Printf("Search: %v, falling back to %s", err, DefaultSearchLanguage)
+		cfg = DefaultSearchLanguage
+	}
+
+	var previous string
+	err = db.QueryRow("SELECT config::text FROM search_languages WHERE folder = ''").Scan(&previous)
+	if err == nil && previous == cfg {
+		return
+	}
+	if _, err := db.Exec(`
+		INSERT INTO search_languages (folder, config) VALUES ('', $1::regconfig)
+		ON CONFLICT (folder) DO UPDATE SET config = EXCLUDED.config
+	`, cfg); err != nil {
+		log.Printf("Search: failed to store the default language: %v", err)
+		return
+	}
+	// Without a previous row the notes predate languages and searchIndexVersion covers them
+	if err == nil {
+		log.Printf("Search: default language changed from %s to %s, re-indexing all notes", previous, cfg)
+		db.Exec("UPDATE notes SET search_version = 0")
+	}
+}
+
+// searchLanguageFor picks the text search configuration for a note: its lang:
+// frontmatter, then the closest folder setting, then the vault-wide default.
+func searchLanguageFor(db *sql.DB, filename string, frontmatter map[string]interface{}) string {
+	if lang := frontmatterString(frontmatter, "lang"); lang != "" {
+		cfg, err := SearchConfig(db, lang)
+		if err == nil {
+			return cfg
+		}
+		log.Printf("Ignoring lang in %s: %v", filename, err)
+	}
+	var cfg string
+	err := db.QueryRow(`
+		SELECT config::text FROM search_languages
+		WHERE folder = '' OR left($1, length(folder) + 1) = folder || '/'
+		ORDER BY length(folder) DESC
+		LIMIT 1
+	`, filepath.ToSlash(filename)).Scan(&cfg)
+	if err != nil {
+		return DefaultSearchLanguage
+	}
+	return cfg
+}
+
+// ReindexPath re-indexes every note below relPath even if unchanged on disk,
+// e.g. after the folder's search language changed.
+func ReindexPath(db *sql.DB, dataDir string, relPath string) {
+	folder := strings.Trim(filepath.ToSlash(filepath.Clean(relPath)), "/")
+	if folder == "." {
+		folder = ""
+	}
+	db.Exec("UPDATE notes SET search_version = 0 WHERE $1 = '' OR left(filename, length($1) + 1) = $1 || '/'", folder)
+	SyncPath(db, dataDir, folder)
+}
+
+// extractHeadings returns the text of the markdown ATX headings in body, one
+// per line, skipping fenced code blocks. Headings are weighted above body text
+// in the search index.
+func extractHeadings(body string) string {
+	var headings []string
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		text := strings.TrimLeft(trimmed, "#")
+		// "#tag" is a tag, not a heading; headings need a space after the hashes
+		if len(trimmed)-len(text) > 6 || (text != "" && text[0] != ' ' && text[0] != '\t') {
+			continue
+		}
+		if text = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(text), "#")); text != "" {
+			headings = append(headings, text)
+		}
+	}
+	return strings.Join(headings, "\n")
+}
@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	// #tag or #nested/tag, only when preceded by start of line, whitespace or an opening bracket
+	inlineTagRegex  = regexp.MustCompile(`(?:^|[\s(\[{,;])#([\p{L}\p{N}_\-]+(?:/[\p{L}\p{N}_\-]+)*)`)
+	inlineCodeRegex = regexp.MustCompile("`[^`\n]*`")
+	headingRegex    = regexp.MustCompile(`^\s{0,3}#{1,6}(\s|$)`)
+	codeFenceRegex  = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+)
+
+// ExtractTags collects tags from the note body and the frontmatter `tags:` field.
+// Code blocks, inline code and heading lines are skipped. Tags are lowercased,
+// de-duplicated and returned sorted.
+func ExtractTags(body string, frontmatter map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	add := func(raw string) {
+		if tag := normalizeTag(raw); tag != "" {
+			seen[tag] = true
+		}
+	}
+
+	inFence := false
+	fence := ""
+	for _, line := range strings.Split(body, "\n") {
+		if m := codeFenceRegex.FindStringSubmatch(line); m != nil {
+			if !inFence {
+				inFence, fence = true, m[1]
+			} else if m[1] == fence {
+				inFence = false
+			}
+			continue
+		}
+		if inFence || headingRegex.MatchString(line) {
+			continue
+		}
+		line = inlineCodeRegex.ReplaceAllString(line, " ")
+		for _, m := range inlineTagRegex.FindAllStringSubmatch(line, -1) {
+			add(m[1])
+		}
+	}
+
+	if frontmatter != nil {
+		for _, key := range []string{"tags", "tag"} {
+			switch v := frontmatter[key].(type) {
+			case string:
+				// Accept "a, b" and "a b" as well as YAML lists
+				for _, t := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+					add(t)
+				}
+			case []interface{}:
+				for _, item := range v {
+					if s, ok := item.(string); ok {
+						add(s)
+					}
+				}
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// normalizeTag lowercases a tag, strips the leading '#' and stray slashes, and
+// rejects purely numeric tags so issue references like #123 are not indexed.
+func normalizeTag(raw string) string {
+	tag := strings.ToLower(strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "#")), "/"))
+	if tag == "" {
+		return ""
+	}
+	hasLetter := false
+	for _, r := range tag {
+		if unicode.IsSpace(r) {
+			return ""
+		}
+		if !unicode.IsDigit(r) && r != '/' && r != '-' && r != '_' {
+			hasLetter = true
+		}
+	}
+	if !hasLetter {
+		return ""
+	}
+	return tag
+}
+
+func (w *Watcher) indexTags(filename string, tags []string) {
+	var noteID int
+	err := w.db.QueryRow("SELECT id FROM notes WHERE filename = $1", filename).Scan(&noteID)
+	if err != nil {
+		return
+	}
+
+	// Replace the previous tag set for this note
+	w.db.Exec("DELETE FROM note_tags WHERE note_id = $1", noteID)
+
+	for _, tag := range tags {
+		_, err := w.db.Exec(
+			"INSERT INTO note_tags (note_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			noteID, tag,
+		)
+		if err != nil {
+			log.Printf("Error indexing tag %s for %s: %v", tag, filename, err)
+		}
+	}
+}
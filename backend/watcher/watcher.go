@@ -1,12 +1,15 @@
 package watcher
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -14,6 +17,7 @@ import (
 type Watcher struct {
 	db      *sql.DB
 	dataDir string
+	queue   *eventQueue
 }
 
 func NewWatcher(db *sql.DB, dataDir string) *Watcher {
@@ -32,6 +36,8 @@ func (w *Watcher) Start() {
 	}
 	// defer watcher.Close() -> Should run in background
 
+	w.queue = newEventQueue(w)
+
 	go func() {
 		for {
 			select {
@@ -44,20 +50,23 @@ func (w *Watcher) Start() {
 				if event.Has(fsnotify.Create) {
 					info, err := os.Stat(event.Name)
 					if err == nil && info.IsDir() {
-						watcher.Add(event.Name)
-						log.Println("Added new watched directory:", event.Name)
+						w.watchTree(watcher, event.Name)
+						w.queue.Created(event.Name, true)
 						continue
 					}
 				}
 
-				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				// Events are debounced per path; bursts collapse into a single index operation
+				if event.Has(fsnotify.Create) {
+					if strings.HasSuffix(event.Name, ".md") {
+						w.queue.Created(event.Name, false)
+					}
+				} else if event.Has(fsnotify.Write) {
 					if strings.HasSuffix(event.Name, ".md") {
-						log.Println("Modified file:", event.Name)
-						w.ProcessFile(event.Name)
+						w.queue.Index(event.Name)
 					}
 				} else if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
-					log.Println("Removed/Renamed path:", event.Name)
-					w.RemoveFile(event.Name)
+					w.queue.Remove(event.Name)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -72,13 +81,21 @@ func (w *Watcher) Start() {
 	SyncDatabaseWithDisk(w.db, w.dataDir)
 
 	// Watch all active directories
-	filepath.Walk(w.dataDir, func(path string, info os.FileInfo, err error) error {
+	w.watchTree(watcher, w.dataDir)
+
+	log.Printf("Started recursively watching %s for changes", w.dataDir)
+}
+
+// watchTree adds root and every non-hidden directory below it to the watcher.
+// Directories moved into the vault arrive with their subfolders already in place.
+func (w *Watcher) watchTree(watcher *fsnotify.Watcher, root string) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
 
 		// Skip hidden directories like .git
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != "." {
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != "." && path != w.dataDir {
 			return filepath.SkipDir
 		}
 
@@ -86,15 +103,34 @@ func (w *Watcher) Start() {
 			err = watcher.Add(path)
 			if err != nil {
 				log.Printf("Error adding watcher directory %s: %v", path, err)
+			} else if path != w.dataDir {
+				log.Println("Added new watched directory:", path)
 			}
 		}
 		return nil
 	})
+}
 
-	log.Printf("Started recursively watching %s for changes", w.dataDir)
+// isHidden reports whether path lies in a dot-directory (or is a dotfile) inside the vault.
+func (w *Watcher) isHidden(path string) bool {
+	rel, err := filepath.Rel(w.dataDir, path)
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *Watcher) ProcessFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf("Error reading file %s: %v", path, err)
+		return
+	}
 	contentBytes, err := os.ReadFile(path)
 	if err != nil {
 		log.Printf("Error reading file %s: %v", path, err)
@@ -102,6 +138,9 @@ func (w *Watcher) ProcessFile(path string) {
 	}
 
 	content := string(contentBytes)
+	hash := contentHash(contentBytes)
+	size := info.Size()
+	mtime := diskMtime(info)
 
 	// Use relative path for database filename
 	relPath, err := filepath.Rel(w.dataDir, path)
@@ -110,9 +149,25 @@ func (w *Watcher) ProcessFile(path string) {
 	}
 
 	filename := relPath
+
+	// Unchanged content only needs its stat fingerprint refreshed
+	var storedHash sql.NullString
+	var storedVersion int
+	w.db.QueryRow("SELECT content_hash, search_version FROM notes WHERE filename = $1", filename).Scan(&storedHash, &storedVersion)
+	if storedHash.Valid && storedHash.String == hash && storedVersion == searchIndexVersion {
+		w.db.Exec("UPDATE notes SET size = $2, mtime = $3 WHERE filename = $1", filename, size, mtime)
+		return
+	}
+
 	title := strings.TrimSuffix(filepath.Base(path), ".md")
 
-	lines := strings.Split(content, "\n")
+	// Split off YAML/TOML frontmatter so it is stored as JSONB and kept out of the search index
+	frontmatter, body, fmErr := ParseFrontmatter(content)
+	if fmErr != nil {
+		log.Printf("Error parsing frontmatter in %s: %v", path, fmErr)
+	}
+
+	lines := strings.Split(strings.TrimLeft(body, "\r\n"), "\n")
 	if len(lines) > 0 {
 		firstLine := strings.TrimSpace(lines[0])
 		if strings.HasPrefix(firstLine, "# ") {
@@ -120,18 +175,36 @@ func (w *Watcher) ProcessFile(path string) {
 		}
 	}
 
-	// Basic implementation - wait for full frontmatter parser
+	// An explicit title: in frontmatter wins over the heading heuristic
+	if fmTitle := frontmatterString(frontmatter, "title"); fmTitle != "" {
+		title = fmTitle
+	}
 
-	_, err = w.db.Exec(`
-		INSERT INTO notes (filename, title, content, content_vector, last_modified) 
-		VALUES ($1, $2, $3, to_tsvector('english', $3), NOW())
+	// The same configuration must be used when querying, so it is stored with the row
+	lang := searchLanguageFor(w.db, filename, frontmatter)
+
+	var inserted bool
+	err = w.db.QueryRow(`
+		INSERT INTO notes (filename, title, frontmatter, content, content_vector, last_modified, content_hash, size, mtime, search_version, search_config) 
+		VALUES ($1, $2, $3::jsonb, $4,
+			setweight(to_tsvector($11::regconfig, $2), 'A') ||
+			setweight(to_tsvector($11::regconfig, $9), 'B') ||
+			setweight(to_tsvector($11::regconfig, $5), 'D'),
+			NOW(), $6, $7, $8, $10, $11::regconfig)
 		ON CONFLICT (filename) 
 		DO UPDATE SET 
 			title = EXCLUDED.title,
+			frontmatter = EXCLUDED.frontmatter,
 			content = EXCLUDED.content,
-			content_vector = to_tsvector('english', EXCLUDED.content),
-			last_modified = NOW()
-	`, filename, title, content)
+			content_vector = EXCLUDED.content_vector,
+			last_modified = NOW(),
+			content_hash = EXCLUDED.content_hash,
+			size = EXCLUDED.size,
+			mtime = EXCLUDED.mtime,
+			search_version = EXCLUDED.search_version,
+			search_config = EXCLUDED.search_config
+		RETURNING (xmax = 0)
+	`, filename, title, FrontmatterJSON(frontmatter), content, body, hash, size, mtime, extractHeadings(body), searchIndexVersion, lang).Scan(&inserted)
 
 	if err != nil {
 		log.Printf("Error upserting note %s: %v", path, err)
@@ -140,6 +213,14 @@ func (w *Watcher) ProcessFile(path string) {
 
 	// Index wiki-links: parse [[...]] references and update links table
 	w.indexWikiLinks(filename, content)
+
+	// A new note may satisfy [[...]] references made before it existed
+	if inserted {
+		w.resolveIncomingLinks(filename)
+	}
+
+	// Index #tags from the body and tags: from frontmatter
+	w.indexTags(filename, ExtractTags(body, frontmatter))
 }
 
 func (w *Watcher) indexWikiLinks(sourceFilename string, content string) {
@@ -184,6 +265,46 @@ func (w *Watcher) indexWikiLinks(sourceFilename string, content string) {
 	}
 }
 
+// resolveIncomingLinks re-indexes links of notes that mention a newly created note.
+// Without a full re-index on every sync, those references would otherwise stay unresolved.
+func (w *Watcher) resolveIncomingLinks(filename string) {
+	base := strings.TrimSuffix(filepath.Base(filename), ".md")
+	rows, err := w.db.Query(`
+		SELECT filename, content FROM notes
+		WHERE filename <> $1 AND (
+			content LIKE '%[[' || $2 || ']]%' OR
+			content LIKE '%[[' || $2 || '.md]]%' OR
+			content LIKE '%[[' || $3 || ']]%'
+		)
+	`, filename, base, strings.TrimSuffix(filename, ".md"))
+	if err != nil {
+		return
+	}
+	type source struct{ filename, content string }
+	var sources []source
+	for rows.Next() {
+		var s source
+		if err := rows.Scan(&s.filename, &s.content); err == nil {
+			sources = append(sources, s)
+		}
+	}
+	rows.Close()
+
+	for _, s := range sources {
+		w.indexWikiLinks(s.filename, s.content)
+	}
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// diskMtime normalises a file's mtime to what a Postgres TIMESTAMP column round-trips.
+func diskMtime(info os.FileInfo) time.Time {
+	return info.ModTime().UTC().Truncate(time.Microsecond)
+}
+
 func (w *Watcher) RemoveFile(path string) {
 	relPath, err := filepath.Rel(w.dataDir, path)
 	if err != nil {
@@ -196,30 +317,192 @@ func (w *Watcher) RemoveFile(path string) {
 	if strings.HasSuffix(filename, ".md") {
 		_, err = w.db.Exec("DELETE FROM notes WHERE filename = $1", filename)
 	} else {
-		_, err = w.db.Exec("DELETE FROM notes WHERE filename = $1 OR filename LIKE $2", filename, filename+"/%")
+		_, err = w.db.Exec("DELETE FROM notes WHERE filename = $1 OR left(filename, length($1) + 1) = $1 || '/'", filename)
 	}
 	if err != nil {
 		log.Printf("Error deleting note/folder %s: %v", path, err)
 	}
 }
 
+// sameContent reports whether what now sits at newPath is what was indexed at
+// oldPath: for a note the same size and content hash, for a folder the same
+// number of notes.
+func (w *Watcher) sameContent(oldPath, newPath string, isDir bool) bool {
+	oldRel, err := filepath.Rel(w.dataDir, oldPath)
+	if err != nil {
+		return false
+	}
+
+	if isDir {
+		var indexed int
+		if err := w.db.QueryRow("SELECT COUNT(*) FROM notes WHERE left(filename, length($1) + 1) = $1 || '/'", oldRel).Scan(&indexed); err != nil {
+			return false
+		}
+		found := 0
+		filepath.Walk(newPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != newPath {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
+				found++
+			}
+			return nil
+		})
+		return found == indexed
+	}
+
+	var size sql.NullInt64
+	var hash sql.NullString
+	if err := w.db.QueryRow("SELECT size, content_hash FROM notes WHERE filename = $1", oldRel).Scan(&size, &hash); err != nil {
+		return false
+	}
+	info, err := os.Stat(newPath)
+	if err != nil || !size.Valid || info.Size() != size.Int64 {
+		return false
+	}
+	data, err := os.ReadFile(newPath)
+	return err == nil && contentHash(data) == hash.String
+}
+
+// RenameFile moves a note's row to its new filename, keeping its ID so links
+// and tags that reference it survive the move. The row is marked for
+// re-indexing since the new folder may use another search language.
+func (w *Watcher) RenameFile(oldPath, newPath string) {
+	oldRel, err := filepath.Rel(w.dataDir, oldPath)
+	if err != nil {
+		return
+	}
+	newRel, err := filepath.Rel(w.dataDir, newPath)
+	if err != nil {
+		return
+	}
+
+	// If the destination is already indexed, drop the old row instead of colliding with it
+	_, err = w.db.Exec(`
+		UPDATE notes SET filename = $2, search_version = 0
+		WHERE filename = $1 AND NOT EXISTS (SELECT 1 FROM notes WHERE filename = $2)
+	`, oldRel, newRel)
+	if err != nil {
+		log.Printf("Error renaming note %s to %s: %v", oldRel, newRel, err)
+		return
+	}
+	w.db.Exec("DELETE FROM notes WHERE filename = $1", oldRel)
+}
+
+// RenameFolder rewrites the filename prefix of every note under a moved folder
+// and re-indexes them, as RenameFile does.
+func (w *Watcher) RenameFolder(oldPath, newPath string) {
+	oldRel, err := filepath.Rel(w.dataDir, oldPath)
+	if err != nil {
+		return
+	}
+	newRel, err := filepath.Rel(w.dataDir, newPath)
+	if err != nil {
+		return
+	}
+
+	_, err = w.db.Exec(`
+		UPDATE notes SET filename = $2 || substr(filename, length($1) + 1), search_version = 0
+		WHERE left(filename, length($1) + 1) = $1 || '/'
+		  AND NOT EXISTS (
+			SELECT 1 FROM notes dst WHERE dst.filename = $2 || substr(notes.filename, length($1) + 1)
+		  )
+	`, oldRel, newRel)
+	if err != nil {
+		log.Printf("Error renaming folder %s to %s: %v", oldRel, newRel, err)
+		return
+	}
+	// Folder search languages move with the folder
+	if _, err := w.db.Exec(`
+		UPDATE search_languages SET folder = $2 || substr(folder, length($1) + 1)
+		WHERE folder = $1 OR left(folder, length($1) + 1) = $1 || '/'
+	`, filepath.ToSlash(oldRel), filepath.ToSlash(newRel)); err != nil {
+		log.Printf("Error moving search languages from %s to %s: %v", oldRel, newRel, err)
+	}
+	// Index anything that did not map cleanly onto an existing row
+	w.RemoveFile(oldPath)
+	filepath.Walk(newPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != newPath {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
+			w.ProcessFile(path)
+		}
+		return nil
+	})
+}
+
+// SyncDatabaseWithDisk reconciles the whole vault with the database. Files whose
+// size and mtime match the indexed row are skipped without being read, and files
+// whose content hash is unchanged are not re-indexed.
 func SyncDatabaseWithDisk(db *sql.DB, dataDir string) {
-	log.Println("Database Sync: scanning disk to prune deleted notes and index active ones...")
-	
+	log.Println("Database Sync: scanning disk to prune deleted notes and index changed ones...")
+	syncTree(db, dataDir, "")
+}
+
+// ResyncAll forgets every stored fingerprint and re-indexes all notes from disk.
+func ResyncAll(db *sql.DB, dataDir string) {
+	log.Println("Database Sync: forcing a full re-index of all notes...")
+	db.Exec("UPDATE notes SET content_hash = NULL, size = NULL, mtime = NULL")
+	syncTree(db, dataDir, "")
+}
+
+// SyncPath reconciles a single note or folder (relative to dataDir) after an
+// operation that only touched that path.
+func SyncPath(db *sql.DB, dataDir string, relPath string) {
+	relPath = filepath.Clean(relPath)
+	if relPath == "." || relPath == "" {
+		SyncDatabaseWithDisk(db, dataDir)
+		return
+	}
+
+	w := NewWatcher(db, dataDir)
+	fullPath := filepath.Join(dataDir, relPath)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		// Gone from disk: drop the note, or every note below the folder
+		w.RemoveFile(fullPath)
+		return
+	}
+	if !info.IsDir() {
+		if strings.HasSuffix(info.Name(), ".md") {
+			w.ProcessFile(fullPath)
+		}
+		return
+	}
+	syncTree(db, dataDir, relPath)
+}
+
+type indexedFile struct {
+	size    sql.NullInt64
+	mtime   sql.NullTime
+	version int
+}
+
+// syncTree reconciles every note below prefix ("" for the whole vault).
+func syncTree(db *sql.DB, dataDir string, prefix string) {
+	root := filepath.Join(dataDir, prefix)
+
 	// 1. Walk the filesystem to collect all current markdown files
-	diskFiles := make(map[string]bool)
-	err := filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+	diskFiles := make(map[string]os.FileInfo)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
 		// Skip hidden files/directories
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != "." {
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && info.Name() != "." && path != root {
 			return filepath.SkipDir
 		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".md") {
 			relPath, err := filepath.Rel(dataDir, path)
 			if err == nil {
-				diskFiles[relPath] = true
+				diskFiles[relPath] = info
 			}
 		}
 		return nil
@@ -229,23 +512,29 @@ func SyncDatabaseWithDisk(db *sql.DB, dataDir string) {
 		return
 	}
 
-	// 2. Fetch all tracked notes from the database
-	rows, err := db.Query("SELECT filename FROM notes")
+	// 2. Fetch the tracked notes (and their stat fingerprints) from the database
+	rows, err := db.Query(
+		"SELECT filename, size, mtime, search_version FROM notes WHERE $1 = '' OR left(filename, length($1) + 1) = $1 || '/'",
+		prefix,
+	)
 	if err != nil {
 		log.Printf("Database Sync: query error: %v", err)
 		return
 	}
-	defer rows.Close()
 
+	indexed := make(map[string]indexedFile)
 	var toDelete []string
 	for rows.Next() {
 		var filename string
-		if err := rows.Scan(&filename); err == nil {
-			if !diskFiles[filename] {
+		var f indexedFile
+		if err := rows.Scan(&filename, &f.size, &f.mtime, &f.version); err == nil {
+			indexed[filename] = f
+			if _, ok := diskFiles[filename]; !ok {
 				toDelete = append(toDelete, filename)
 			}
 		}
 	}
+	rows.Close()
 
 	// 3. Delete stale rows
 	for _, filename := range toDelete {
@@ -256,12 +545,17 @@ func SyncDatabaseWithDisk(db *sql.DB, dataDir string) {
 		}
 	}
 
-	// 4. Index active files to ensure they are synchronized
+	// 4. Index files that are new or whose size/mtime changed
 	w := NewWatcher(db, dataDir)
-	for relPath := range diskFiles {
-		fullPath := filepath.Join(dataDir, relPath)
-		w.ProcessFile(fullPath)
+	processed := 0
+	for relPath, info := range diskFiles {
+		if f, ok := indexed[relPath]; ok && f.size.Valid && f.mtime.Valid && f.version == searchIndexVersion &&
+			f.size.Int64 == info.Size() && f.mtime.Time.Equal(diskMtime(info)) {
+			continue
+		}
+		w.ProcessFile(filepath.Join(dataDir, relPath))
+		processed++
 	}
-	
-	log.Printf("Database Sync: complete! Pruned %d stale rows, validated %d active files.", len(toDelete), len(diskFiles))
+
+	log.Printf("Database Sync: complete! Pruned %d stale rows, checked %d changed of %d active files.", len(toDelete), processed, len(diskFiles))
 }
@@ -13,6 +13,10 @@ export default function Home() {
   const pathname = usePathname();
 
   const [notes, setNotes] = useState<any[]>([]);
+  // Paging of search results: the total match count and the cursor for the next page
+  const [notesTotal, setNotesTotal] = useState(0);
+  const [notesCursor, setNotesCursor] = useState("");
+  const searchQueryRef = useRef("");
   const [treeData, setTreeData] = useState<any[]>([]);
   const [selectedNote, setSelectedNote] = useState<any | null>(null);
   const [currentView, setCurrentView] = useState<"editor" | "settings">("editor");
@@ -48,19 +52,35 @@ export default function Home() {
     } catch (e) { console.error(e) }
   };
 
-  const fetchNotes = async (query: string = "") => {
+  const fetchNotes = async (query: string = "", cursor: string = "") => {
     try {
-      const url = query ? `/api/search?q=${encodeURIComponent(query)}` : "/api/notes";
+      searchQueryRef.current = query;
+      let url = query ? `/api/search?q=${encodeURIComponent(query)}` : "/api/notes";
+      if (query && cursor) url += `&cursor=${encodeURIComponent(cursor)}`;
       const res = await fetch(url);
-      if (res.ok) {
+      if (res.ok && searchQueryRef.current === query) {
         const data = await res.json();
-        setNotes(data || []);
+        if (!query) {
+          setNotes(data || []);
+          setNotesTotal(0);
+          setNotesCursor("");
+          return;
+        }
+        // Search responses are paged: { results, total, next_cursor }
+        const page = data?.results || [];
+        setNotes((prev) => cursor ? [...prev, ...page] : page);
+        setNotesTotal(data?.total || 0);
+        setNotesCursor(data?.next_cursor || "");
       }
     } catch (e) {
       console.error("Failed to fetch notes", e);
     }
   };
 
+  const loadMoreNotes = () => {
+    if (notesCursor) fetchNotes(searchQueryRef.current, notesCursor);
+  };
+
   const handleRefreshWorkspace = async () => {
     try {
       await fetch("/api/sync", { method: "POST" });
@@ -254,7 +274,9 @@ export default function Home() {
           fetchTree();
         }, 500);
       } else {
-        console.error("Failed to save note", await res.text());
+        const message = await res.text();
+        console.error("Failed to save note", message);
+        alert("Failed to save note: " + message);
       }
     } catch (e) {
       console.error("Failed to save note", e);
@@ -297,6 +319,8 @@ export default function Home() {
         ">
           <Sidebar
             notes={notes}
+            notesTotal={notesTotal}
+            onLoadMoreNotes={notesCursor ? loadMoreNotes : undefined}
             treeData={treeData}
             onSelectNote={(note) => { setSidebarOpen(false); requestNavigation('select', note); }}
             onCreateNote={() => { setSidebarOpen(false); requestNavigation('create'); }}
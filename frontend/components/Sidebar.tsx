@@ -8,11 +8,14 @@ interface TreeItem {
     path: string;
     type: string;
     lastModified?: string;
+    query?: string;
     children?: TreeItem[];
 }
 
 interface SidebarProps {
     notes: any[];
+    notesTotal?: number;
+    onLoadMoreNotes?: () => void;
     treeData: TreeItem[];
     onSelectNote: (note: any) => void;
     onCreateNote: () => void;
@@ -57,6 +60,32 @@ function TreeNode({
     const [isOpen, setIsOpen] = useState(defaultOpen);
     const [isDragOver, setIsDragOver] = useState(false);
     const [isMenuOpen, setIsMenuOpen] = useState(false);
+    // Results of a smart folder, fetched page by page while it is open
+    const [searchResults, setSearchResults] = useState<TreeItem[]>([]);
+    const [searchTotal, setSearchTotal] = useState(0);
+    const [searchCursor, setSearchCursor] = useState("");
+
+    const loadSearchResults = async (cursor = "") => {
+        const id = item.path.replace("saved-search:", "");
+        try {
+            const res = await fetch(`/api/saved-searches/${id}/results?limit=50${cursor ? `&cursor=${encodeURIComponent(cursor)}` : ""}`);
+            if (!res.ok) return;
+            const data = await res.json();
+            const page: TreeItem[] = (data.results || []).map((n: any) => ({
+                name: n.filename.split('/').pop(),
+                path: n.filename,
+                type: "file",
+                lastModified: n.last_modified
+            }));
+            setSearchResults(prev => cursor ? [...prev, ...page] : page);
+            if (!cursor) setSearchTotal(data.total ?? page.length);
+            setSearchCursor(data.next_cursor || "");
+        } catch (err) { console.error(err) }
+    };
+
+    useEffect(() => {
+        if (item.type === "smart_folder" && isOpen) loadSearchResults();
+    }, [isOpen, item.type, item.path]);
 
     useEffect(() => {
         if (expandSignal > 0) setIsOpen(true);
@@ -204,6 +233,66 @@ function TreeNode({
         onDragLeave: handleDragLeave,
     };
 
+    // Saved searches: virtual folders listing the current results, not a place to drop or create notes
+    if (item.type === "smart_folder") {
+        const handleDeleteSearch = async (e: React.MouseEvent) => {
+            e.stopPropagation();
+            if (!confirm(`Delete the saved search "${item.name}"? Notes are not affected.`)) return;
+            try {
+                await fetch(`/api/saved-searches/${item.path.replace("saved-search:", "")}`, { method: "DELETE" });
+                onRefresh();
+            } catch (err) { console.error(err) }
+        };
+
+        return (
+            <div>
+                <div className="w-full group flex items-center justify-between transition text-sm text-foreground pr-4 hover:bg-muted/50" style={{ paddingLeft: `${level * 16 + 16}px` }}>
+                    <button
+                        onClick={() => setIsOpen(!isOpen)}
+                        title={item.query}
+                        className="flex-1 text-left flex items-start gap-1.5 py-1.5"
+                    >
+                        {isOpen ? <ChevronDown size={14} className="opacity-70 shrink-0 mt-0.5" /> : <ChevronRight size={14} className="opacity-70 shrink-0 mt-0.5" />}
+                        <Search size={16} className="text-secondary-foreground shrink-0 mt-0.5" />
+                        <span className="break-words leading-tight flex-1 italic">{item.name}</span>
+                    </button>
+                    <button
+                        onClick={handleDeleteSearch}
+                        title="Delete saved search"
+                        className="p-1 hover:text-destructive transition-colors opacity-0 group-hover:opacity-100"
+                    >
+                        <X size={14} />
+                    </button>
+                </div>
+                {isOpen && searchResults.map((child) => (
+                    <TreeNode
+                        key={`${item.path}/${child.path}`}
+                        item={child}
+                        level={level + 1}
+                        onSelect={onSelect}
+                        onRefresh={onRefresh}
+                        expandSignal={expandSignal}
+                        collapseSignal={collapseSignal}
+                        conflictedNote={conflictedNote}
+                        onInitMove={onInitMove}
+                        selectedNotePath={selectedNotePath}
+                        onRenameNote={onRenameNote}
+                    />
+                ))}
+                {isOpen && searchTotal > searchResults.length && (
+                    <div className="flex items-center justify-between text-xs text-muted-foreground pr-4 py-1" style={{ paddingLeft: `${(level + 1) * 16 + 16 + 18}px` }}>
+                        <span>{searchResults.length} of {searchTotal}</span>
+                        {searchCursor && (
+                            <button onClick={() => loadSearchResults(searchCursor)} className="hover:text-foreground transition-colors">
+                                Load more
+                            </button>
+                        )}
+                    </div>
+                )}
+            </div>
+        );
+    }
+
     if (item.type === "folder") {
         return (
             <div {...dropProps}>
@@ -369,6 +458,8 @@ function MoveModal({
 
 export default function Sidebar({
     notes,
+    notesTotal = 0,
+    onLoadMoreNotes,
     treeData,
     onSelectNote,
     onCreateNote,
@@ -397,6 +488,9 @@ export default function Sidebar({
 
     const sortNodes = (nodes: TreeItem[]): TreeItem[] => {
         return [...nodes].sort((a, b) => {
+            // Saved searches lead the tree
+            if ((a.type === "smart_folder") !== (b.type === "smart_folder")) return a.type === "smart_folder" ? -1 : 1;
+
             // Always sort folders first
             if (a.type === "folder" && b.type === "file") return -1;
             if (a.type === "file" && b.type === "folder") return 1;
@@ -413,7 +507,8 @@ export default function Sidebar({
             return 0;
         }).map(node => ({
             ...node,
-            children: node.children ? sortNodes(node.children) : []
+            // Smart folder results keep their relevance order
+            children: node.children ? (node.type === "smart_folder" ? node.children : sortNodes(node.children)) : []
         }));
     };
 
@@ -634,6 +729,16 @@ export default function Sidebar({
                                     </button>
                                 </li>
                             ))}
+                            {notesTotal > notes.length && (
+                                <li className="flex items-center justify-between px-4 py-2 text-xs text-muted-foreground">
+                                    <span>{notes.length} of {notesTotal}</span>
+                                    {onLoadMoreNotes && (
+                                        <button onClick={onLoadMoreNotes} className="hover:text-foreground transition-colors">
+                                            Load more
+                                        </button>
+                                    )}
+                                </li>
+                            )}
                         </ul>
                     )
                 ) : (